@@ -0,0 +1,89 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BytesToTar wraps data into a tar stream holding a single file called name,
+// ready to be passed to CopyTo.
+func BytesToTar(name string, data []byte) (io.Reader, error) {
+	return buildTar(name, data, 0644, time.Now())
+}
+
+// FileToTar wraps the local file at path into a tar stream, keeping its base
+// name, mode and modification time.
+func FileToTar(path string) (io.Reader, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return buildTar(info.Name(), data, int64(info.Mode().Perm()), info.ModTime())
+}
+
+// TarToBytes returns the name and the content of the first regular file
+// found in the tar stream, as returned by CopyFrom.
+func TarToBytes(r io.Reader) (string, []byte, error) {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return "", nil, fmt.Errorf("no regular file found in archive")
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return "", nil, err
+		}
+		return filepath.Base(hdr.Name), data, nil
+	}
+}
+
+// TarToFile extracts the first regular file of the tar stream to dstPath.
+func TarToFile(r io.Reader, dstPath string) error {
+	_, data, err := TarToBytes(r)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dstPath, data, 0644)
+}
+
+func buildTar(name string, data []byte, mode int64, modTime time.Time) (io.Reader, error) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	hdr := &tar.Header{
+		Name:     filepath.Base(name),
+		Mode:     mode,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
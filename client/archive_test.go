@@ -0,0 +1,49 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+)
+
+func TestBytesToTarAndBack(t *testing.T) {
+	r, err := BytesToTar("/tmp/upload.txt", []byte("some-content"))
+	AssertThat(t, err, Is{nil})
+	name, data, err := TarToBytes(r)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, name, EqualTo{"upload.txt"})
+	AssertThat(t, string(data), EqualTo{"some-content"})
+}
+
+func TestFileToTarAndBack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	AssertThat(t, err, Is{nil})
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "download.bin")
+	AssertThat(t, ioutil.WriteFile(src, []byte{1, 2, 3}, 0600), Is{nil})
+	r, err := FileToTar(src)
+	AssertThat(t, err, Is{nil})
+
+	dst := filepath.Join(dir, "copy.bin")
+	AssertThat(t, TarToFile(r, dst), Is{nil})
+	data, err := ioutil.ReadFile(dst)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, data, EqualTo{[]byte{1, 2, 3}})
+}
+
+func TestFileToTarDirectory(t *testing.T) {
+	_, err := FileToTar(os.TempDir())
+	AssertThat(t, err, Not{nil})
+}
+
+func TestTarToBytesEmptyArchive(t *testing.T) {
+	r, err := BytesToTar("empty", nil)
+	AssertThat(t, err, Is{nil})
+	ioutil.ReadAll(r)
+	_, _, err = TarToBytes(r)
+	AssertThat(t, err, Not{nil})
+}
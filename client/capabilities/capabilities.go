@@ -0,0 +1,234 @@
+// Package capabilities parses the capabilities of WebDriver new session
+// requests, in W3C and legacy form, and matches them against a browser
+// catalog.
+package capabilities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	blueclient "github.com/kolobok01/util/client"
+)
+
+const selenoidOptions = "selenoid:options"
+
+// Options are the vendor options of the session, sent as selenoid:options
+// or, in legacy requests, as top-level capabilities.
+type Options struct {
+	Name             string            `json:"name,omitempty"`
+	EnableVNC        bool              `json:"enableVNC,omitempty"`
+	EnableVideo      bool              `json:"enableVideo,omitempty"`
+	VideoName        string            `json:"videoName,omitempty"`
+	ScreenResolution string            `json:"screenResolution,omitempty"`
+	TimeZone         string            `json:"timeZone,omitempty"`
+	SessionTimeout   string            `json:"sessionTimeout,omitempty"`
+	Env              []string          `json:"env,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+}
+
+type Capabilities struct {
+	BrowserName    string
+	BrowserVersion string
+	PlatformName   string
+	Options        Options
+	// Raw are the capabilities as sent, alwaysMatch merged with the
+	// firstMatch alternative for W3C requests.
+	Raw map[string]interface{}
+}
+
+type newSession struct {
+	Capabilities *struct {
+		AlwaysMatch map[string]interface{}   `json:"alwaysMatch"`
+		FirstMatch  []map[string]interface{} `json:"firstMatch"`
+	} `json:"capabilities"`
+	DesiredCapabilities map[string]interface{} `json:"desiredCapabilities"`
+}
+
+// Parse returns the alternatives of a new session request in order of
+// preference. The W3C capabilities win over the legacy desiredCapabilities
+// that clients send along for older hubs, unless they name no browser.
+func Parse(data []byte) ([]Capabilities, error) {
+	var s newSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("cannot parse new session request: %v", err)
+	}
+	if s.Capabilities != nil {
+		alternatives, err := parseW3C(s.Capabilities.AlwaysMatch, s.Capabilities.FirstMatch)
+		if err != nil {
+			return nil, err
+		}
+		// clients sending both forms may leave the browser out of the W3C
+		// one, e.g. {"firstMatch": [{}]}
+		if s.DesiredCapabilities == nil || hasBrowserName(alternatives) {
+			return alternatives, nil
+		}
+	}
+	if s.DesiredCapabilities != nil {
+		c, err := extract(s.DesiredCapabilities, true)
+		if err != nil {
+			return nil, err
+		}
+		return []Capabilities{c}, nil
+	}
+	return nil, errors.New("new session request has neither capabilities nor desiredCapabilities")
+}
+
+func hasBrowserName(alternatives []Capabilities) bool {
+	for _, c := range alternatives {
+		if c.BrowserName != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func parseW3C(alwaysMatch map[string]interface{}, firstMatch []map[string]interface{}) ([]Capabilities, error) {
+	if len(firstMatch) == 0 {
+		firstMatch = []map[string]interface{}{{}}
+	}
+	var alternatives []Capabilities
+	for _, first := range firstMatch {
+		merged, err := merge(alwaysMatch, first)
+		if err != nil {
+			return nil, err
+		}
+		c, err := extract(merged, false)
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, c)
+	}
+	return alternatives, nil
+}
+
+// merge fails on capabilities set both in alwaysMatch and in firstMatch,
+// like the W3C specification requires.
+func merge(alwaysMatch, firstMatch map[string]interface{}) (map[string]interface{}, error) {
+	merged := make(map[string]interface{})
+	for k, v := range alwaysMatch {
+		merged[k] = v
+	}
+	for k, v := range firstMatch {
+		if _, ok := alwaysMatch[k]; ok {
+			return nil, fmt.Errorf("capability %s is both in alwaysMatch and firstMatch", k)
+		}
+		merged[k] = v
+	}
+	return merged, nil
+}
+
+// extract reads the W3C names first and then the legacy ones, so that both
+// forms work in both kinds of requests.
+func extract(raw map[string]interface{}, legacy bool) (Capabilities, error) {
+	c := Capabilities{Raw: raw}
+	var err error
+	if c.BrowserName, err = stringCapability(raw, "browserName"); err != nil {
+		return c, err
+	}
+	if c.BrowserVersion, err = stringCapability(raw, "browserVersion", "version"); err != nil {
+		return c, err
+	}
+	if c.PlatformName, err = stringCapability(raw, "platformName", "platform"); err != nil {
+		return c, err
+	}
+	if legacy {
+		if err := decode(raw, &c.Options); err != nil {
+			return c, err
+		}
+	}
+	if options, ok := raw[selenoidOptions]; ok {
+		if err := decode(options, &c.Options); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+func stringCapability(raw map[string]interface{}, names ...string) (string, error) {
+	for _, name := range names {
+		value, ok := raw[name]
+		if !ok || value == nil {
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("capability %s should be a string, got: %v", name, value)
+		}
+		if s != "" {
+			return s, nil
+		}
+	}
+	return "", nil
+}
+
+// decode fills the fields the value sets and leaves the others alone.
+func decode(value interface{}, options *Options) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, options); err != nil {
+		return fmt.Errorf("incorrect %s: %v", selenoidOptions, err)
+	}
+	return nil
+}
+
+// Match is the alternative chosen for a session with its catalog entry.
+type Match struct {
+	Capabilities Capabilities
+	Browser      *blueclient.ResolvedBrowser
+}
+
+// Resolve returns the first alternative the catalog has an image for, or
+// the error of the first alternative when there is none.
+func Resolve(catalog blueclient.Catalog, alternatives []Capabilities) (*Match, error) {
+	var firstErr error
+	for _, c := range alternatives {
+		if c.BrowserName == "" {
+			if firstErr == nil {
+				firstErr = errors.New("capability browserName is not set")
+			}
+			continue
+		}
+		browser, err := catalog.Resolve(c.BrowserName, c.BrowserVersion)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		return &Match{Capabilities: c, Browser: browser}, nil
+	}
+	if firstErr == nil {
+		firstErr = errors.New("no capabilities to match")
+	}
+	return nil, firstErr
+}
+
+// Env is the environment of the catalog entry overridden by the env
+// option.
+func (m *Match) Env() map[string]string {
+	env := m.Browser.EnvMap()
+	for k, v := range blueclient.ParseEnv(m.Capabilities.Options.Env) {
+		env[k] = v
+	}
+	return env
+}
+
+// Settings returns the settings of the session, the image is
+// m.Browser.Image.
+func (m *Match) Settings() blueclient.SessionSettings {
+	opts := m.Capabilities.Options
+	return blueclient.SessionSettings{
+		Labels:           opts.Labels,
+		Browser:          m.Browser.Browser,
+		Version:          m.Browser.Version,
+		Port:             m.Browser.WebDriverPort(),
+		Path:             m.Browser.Path,
+		Env:              m.Env(),
+		ScreenResolution: opts.ScreenResolution,
+		TimeZone:         opts.TimeZone,
+		EnableVNC:        opts.EnableVNC,
+	}
+}
@@ -0,0 +1,123 @@
+package capabilities
+
+import (
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+const testCatalog = `{
+	"chrome": {
+		"default": "70.0",
+		"versions": {
+			"70.0": {"image": "blueio/images:chrome_70.0", "env": ["LANG=en_US.UTF-8", "TZ=UTC"]},
+			"120.0.6099": {"image": "blueio/images:chrome_120.0.6099"}
+		}
+	},
+	"firefox": {
+		"versions": {
+			"63.0": {"image": "blueio/images:firefox_63.0", "port": "4445"}
+		}
+	}
+}`
+
+func TestParseW3C(t *testing.T) {
+	alternatives, err := Parse([]byte(`{
+		"capabilities": {
+			"alwaysMatch": {"platformName": "linux", "selenoid:options": {"enableVNC": true, "screenResolution": "1920x1080x24"}},
+			"firstMatch": [{"browserName": "opera"}, {"browserName": "chrome", "browserVersion": "120"}]
+		},
+		"desiredCapabilities": {"browserName": "firefox"}
+	}`))
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, len(alternatives), EqualTo{2})
+	AssertThat(t, alternatives[0].BrowserName, EqualTo{"opera"})
+	c := alternatives[1]
+	AssertThat(t, c.BrowserName, EqualTo{"chrome"})
+	AssertThat(t, c.BrowserVersion, EqualTo{"120"})
+	AssertThat(t, c.PlatformName, EqualTo{"linux"})
+	AssertThat(t, c.Options.EnableVNC, Is{true})
+	AssertThat(t, c.Options.ScreenResolution, EqualTo{"1920x1080x24"})
+}
+
+func TestParseW3CWithoutFirstMatch(t *testing.T) {
+	alternatives, err := Parse([]byte(`{"capabilities": {"alwaysMatch": {"browserName": "firefox"}}}`))
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, len(alternatives), EqualTo{1})
+	AssertThat(t, alternatives[0].BrowserName, EqualTo{"firefox"})
+}
+
+func TestParseW3CWithoutBrowserName(t *testing.T) {
+	alternatives, err := Parse([]byte(`{
+		"capabilities": {"firstMatch": [{}]},
+		"desiredCapabilities": {"browserName": "firefox", "version": "63.0"}
+	}`))
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, len(alternatives), EqualTo{1})
+	AssertThat(t, alternatives[0].BrowserName, EqualTo{"firefox"})
+	AssertThat(t, alternatives[0].BrowserVersion, EqualTo{"63.0"})
+}
+
+func TestParseW3CConflict(t *testing.T) {
+	_, err := Parse([]byte(`{"capabilities": {"alwaysMatch": {"browserName": "firefox"}, "firstMatch": [{"browserName": "chrome"}]}}`))
+	AssertThat(t, err, Not{nil})
+}
+
+func TestParseLegacy(t *testing.T) {
+	alternatives, err := Parse([]byte(`{
+		"desiredCapabilities": {
+			"browserName": "firefox", "version": "63.0", "platform": "LINUX",
+			"enableVNC": true, "timeZone": "Europe/Moscow",
+			"selenoid:options": {"timeZone": "UTC", "env": ["LANG=ru_RU.UTF-8"]}
+		}
+	}`))
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, len(alternatives), EqualTo{1})
+	c := alternatives[0]
+	AssertThat(t, c.BrowserVersion, EqualTo{"63.0"})
+	AssertThat(t, c.PlatformName, EqualTo{"LINUX"})
+	AssertThat(t, c.Options.EnableVNC, Is{true})
+	AssertThat(t, c.Options.TimeZone, EqualTo{"UTC"})
+	AssertThat(t, c.Options.Env, EqualTo{[]string{"LANG=ru_RU.UTF-8"}})
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, data := range []string{
+		`{}`,
+		`[]`,
+		`{"desiredCapabilities": {"browserName": 42}}`,
+		`{"capabilities": {"alwaysMatch": {"browserName": "chrome", "selenoid:options": {"enableVNC": "yes"}}}}`,
+	} {
+		_, err := Parse([]byte(data))
+		AssertThat(t, err, Not{nil})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	catalog, err := blueclient.ParseCatalog([]byte(testCatalog))
+	AssertThat(t, err, Is{nil})
+	alternatives, err := Parse([]byte(`{
+		"capabilities": {
+			"alwaysMatch": {"selenoid:options": {"env": ["TZ=Europe/Moscow"]}},
+			"firstMatch": [{"browserName": "opera"}, {"browserName": "chrome"}]
+		}
+	}`))
+	AssertThat(t, err, Is{nil})
+
+	m, err := Resolve(catalog, alternatives)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, m.Browser.Image, EqualTo{"blueio/images:chrome_70.0"})
+	AssertThat(t, m.Env(), EqualTo{map[string]string{"LANG": "en_US.UTF-8", "TZ": "Europe/Moscow"}})
+}
+
+func TestResolveUnknown(t *testing.T) {
+	catalog, err := blueclient.ParseCatalog([]byte(testCatalog))
+	AssertThat(t, err, Is{nil})
+
+	_, err = Resolve(catalog, []Capabilities{{BrowserName: "chrome", BrowserVersion: "71"}, {BrowserName: "opera"}})
+	AssertThat(t, err, EqualTo{&blueclient.UnknownBrowserError{Browser: "chrome", Version: "71"}})
+
+	_, err = Resolve(catalog, []Capabilities{{}})
+	AssertThat(t, err, Not{nil})
+}
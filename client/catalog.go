@@ -0,0 +1,185 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Catalog maps browser names and versions to session images. It is read
+// from a file in the format of the browsers.json of Selenoid:
+//
+//	{
+//	  "chrome": {
+//	    "default": "120.0",
+//	    "versions": {
+//	      "120.0": {"image": "blueio/images:chrome_120.0", "port": "4444", "path": "/"}
+//	    }
+//	  }
+//	}
+type Catalog map[string]*Browser
+
+type Browser struct {
+	// Default is the version of requests without one, the highest version
+	// when empty.
+	Default  string                     `json:"default,omitempty"`
+	Versions map[string]*BrowserVersion `json:"versions"`
+}
+
+type BrowserVersion struct {
+	Image string `json:"image"`
+	// Port of the WebDriver, 4444 by default. It is a string like in
+	// Selenoid.
+	Port  string            `json:"port,omitempty"`
+	Path  string            `json:"path,omitempty"`
+	Tmpfs map[string]string `json:"tmpfs,omitempty"`
+	// Env is a list of KEY=VALUE pairs.
+	Env []string `json:"env,omitempty"`
+}
+
+// WebDriverPort returns the port, or the default one when it is not set.
+func (v *BrowserVersion) WebDriverPort() int {
+	port, err := strconv.Atoi(v.Port)
+	if err != nil || port == 0 {
+		return DefaultWebDriverPort
+	}
+	return port
+}
+
+// EnvMap returns Env as a map.
+func (v *BrowserVersion) EnvMap() map[string]string {
+	return ParseEnv(v.Env)
+}
+
+// ParseEnv converts KEY=VALUE pairs to a map, entries without = get an
+// empty value.
+func ParseEnv(vars []string) map[string]string {
+	env := make(map[string]string)
+	for _, e := range vars {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) == 2 {
+			env[kv[0]] = kv[1]
+		} else {
+			env[kv[0]] = ""
+		}
+	}
+	return env
+}
+
+// ResolvedBrowser is the catalog entry chosen for a request.
+type ResolvedBrowser struct {
+	Browser string
+	Version string
+	*BrowserVersion
+}
+
+// UnknownBrowserError is returned when the catalog has no image for the
+// requested browser and version.
+type UnknownBrowserError struct {
+	Browser string
+	Version string
+}
+
+func (e *UnknownBrowserError) Error() string {
+	if e.Version == "" {
+		return fmt.Sprintf("unknown browser %s", e.Browser)
+	}
+	return fmt.Sprintf("unknown browser %s %s", e.Browser, e.Version)
+}
+
+func LoadCatalog(path string) (Catalog, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCatalog(data)
+}
+
+func ParseCatalog(data []byte) (Catalog, error) {
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("cannot parse catalog: %v", err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c Catalog) validate() error {
+	for name, browser := range c {
+		if browser == nil || len(browser.Versions) == 0 {
+			return fmt.Errorf("browser %s has no versions", name)
+		}
+		if browser.Default != "" && browser.Versions[browser.Default] == nil {
+			return fmt.Errorf("default version %s of %s is not in its versions", browser.Default, name)
+		}
+		for version, v := range browser.Versions {
+			if v == nil || v.Image == "" {
+				return fmt.Errorf("browser %s %s has no image", name, version)
+			}
+			if v.Port != "" {
+				if _, err := strconv.Atoi(v.Port); err != nil {
+					return fmt.Errorf("browser %s %s has an incorrect port %q", name, version, v.Port)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Versions returns the versions of the browser from the lowest to the
+// highest.
+func (c Catalog) Versions(browser string) []string {
+	b, ok := c[browser]
+	if !ok {
+		return nil
+	}
+	var versions []string
+	for v := range b.Versions {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return CompareVersions(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+// Resolve chooses the image of a browser version. An empty version gives
+// the default one, a version missing from the catalog is taken as a prefix
+// and gives the highest version it prefixes, i.e. chrome 120 resolves to
+// 120.0.6099 rather than 120.0.
+func (c Catalog) Resolve(browser, version string) (*ResolvedBrowser, error) {
+	b, ok := c[browser]
+	if !ok {
+		return nil, &UnknownBrowserError{Browser: browser, Version: version}
+	}
+	if version == "" {
+		version = b.Default
+	}
+	if v, ok := b.Versions[version]; ok {
+		return &ResolvedBrowser{Browser: browser, Version: version, BrowserVersion: v}, nil
+	}
+	versions := c.Versions(browser)
+	for i := len(versions) - 1; i >= 0; i-- {
+		if version == "" || HasVersionPrefix(versions[i], version) {
+			return &ResolvedBrowser{Browser: browser, Version: versions[i], BrowserVersion: b.Versions[versions[i]]}, nil
+		}
+	}
+	return nil, &UnknownBrowserError{Browser: browser, Version: version}
+}
+
+// ResolveRequest resolves requests like "chrome" or "chrome 120".
+func (c Catalog) ResolveRequest(request string) (*ResolvedBrowser, error) {
+	fields := strings.Fields(request)
+	switch len(fields) {
+	case 1:
+		return c.Resolve(fields[0], "")
+	case 2:
+		return c.Resolve(fields[0], fields[1])
+	}
+	return nil, fmt.Errorf("incorrect browser request %q, should be <browser> [<version>]", request)
+}
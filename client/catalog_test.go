@@ -0,0 +1,100 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+)
+
+const testCatalog = `{
+	"chrome": {
+		"default": "70.0",
+		"versions": {
+			"70.0": {"image": "blueio/images:chrome_70.0", "port": "4444"},
+			"120.0": {"image": "blueio/images:chrome_120.0", "port": "4444"},
+			"120.0.6099": {"image": "blueio/images:chrome_120.0.6099", "port": "4444", "env": ["LANG=en_US.UTF-8"]}
+		}
+	},
+	"safari": {
+		"versions": {
+			"10.9": {"image": "blueio/images:safari_10.9"},
+			"10.10": {"image": "blueio/images:safari_10.10", "port": "5555", "path": "/wd/hub", "tmpfs": {"/tmp": "size=128m"}}
+		}
+	}
+}`
+
+func TestResolve(t *testing.T) {
+	c, err := ParseCatalog([]byte(testCatalog))
+	AssertThat(t, err, Is{nil})
+
+	r, err := c.ResolveRequest("chrome")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, r.Version, EqualTo{"70.0"})
+	AssertThat(t, r.Image, EqualTo{"blueio/images:chrome_70.0"})
+
+	r, err = c.ResolveRequest("chrome 120")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, r.Version, EqualTo{"120.0.6099"})
+	AssertThat(t, r.EnvMap(), EqualTo{map[string]string{"LANG": "en_US.UTF-8"}})
+
+	r, err = c.Resolve("chrome", "120.0")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, r.Version, EqualTo{"120.0"})
+
+	r, err = c.Resolve("safari", "")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, r.Version, EqualTo{"10.10"})
+	AssertThat(t, r.WebDriverPort(), EqualTo{5555})
+	AssertThat(t, r.Path, EqualTo{"/wd/hub"})
+
+	r, err = c.Resolve("safari", "10.9")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, r.WebDriverPort(), EqualTo{DefaultWebDriverPort})
+}
+
+func TestResolveUnknown(t *testing.T) {
+	c, err := ParseCatalog([]byte(testCatalog))
+	AssertThat(t, err, Is{nil})
+
+	for _, request := range []string{"opera", "chrome 12", "chrome 1200", "safari 10.1"} {
+		_, err := c.ResolveRequest(request)
+		_, ok := err.(*UnknownBrowserError)
+		AssertThat(t, ok, Is{true})
+	}
+	_, err = c.ResolveRequest("chrome 70 beta")
+	AssertThat(t, err, Not{nil})
+}
+
+func TestCatalogVersions(t *testing.T) {
+	c, err := ParseCatalog([]byte(testCatalog))
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, c.Versions("chrome"), EqualTo{[]string{"70.0", "120.0", "120.0.6099"}})
+}
+
+func TestParseCatalogErrors(t *testing.T) {
+	for _, data := range []string{
+		`[]`,
+		`{"chrome": {"versions": {}}}`,
+		`{"chrome": {"default": "71.0", "versions": {"70.0": {"image": "blueio/images:chrome_70.0"}}}}`,
+		`{"chrome": {"versions": {"70.0": {"port": "4444"}}}}`,
+		`{"chrome": {"versions": {"70.0": {"image": "blueio/images:chrome_70.0", "port": "http"}}}}`,
+	} {
+		_, err := ParseCatalog([]byte(data))
+		AssertThat(t, err, Not{nil})
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	f, err := ioutil.TempFile("", "browsers")
+	AssertThat(t, err, Is{nil})
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(testCatalog)
+	AssertThat(t, err, Is{nil})
+	f.Close()
+
+	c, err := LoadCatalog(f.Name())
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, len(c), EqualTo{2})
+}
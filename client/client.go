@@ -8,6 +8,18 @@ import (
 type Client interface {
 	GetType() string
 	GetLogs(ctx context.Context, container string) (io.ReadCloser, error)
+	CopyTo(ctx context.Context, container, dstPath string, content io.Reader) error
+	CopyFrom(ctx context.Context, container, srcPath string) (io.ReadCloser, error)
+	Watch(ctx context.Context, selector map[string]string) (<-chan Event, error)
+	Stats(ctx context.Context, container string, stream bool) (<-chan Stats, error)
+	Exec(ctx context.Context, container string, cmd []string) (*ExecResult, error)
+	WaitReady(ctx context.Context, container string) (*Endpoint, error)
+}
+
+type ExecResult struct {
+	ExitCode int
+	Stdout   []byte
+	Stderr   []byte
 }
 
 const (
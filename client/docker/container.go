@@ -0,0 +1,240 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	blueclient "github.com/kolobok01/util/client"
+	"github.com/kolobok01/util/client/capabilities"
+)
+
+const (
+	vncPort        = 5900
+	localAddress   = "127.0.0.1"
+	defaultShmSize = 256 << 20
+)
+
+// SessionOptions configure the container built by BuildSessionContainer.
+// The zero value gives a container exposing the WebDriver port with a
+// 256Mi /dev/shm.
+type SessionOptions struct {
+	User   string
+	Labels map[string]string
+
+	// Browser and Version label the session, they are parsed from the
+	// image when empty.
+	Browser string
+	Version string
+
+	// Port is the WebDriver port of the image, 4444 by default, and Path
+	// the base path of the WebDriver, / by default.
+	Port             int
+	Path             string
+	Env              map[string]string
+	ScreenResolution string
+	TimeZone         string
+	EnableVNC        bool
+
+	// Memory limit in bytes and CPU limit in cores.
+	Memory  int64
+	CPU     float64
+	ShmSize int64
+	Tmpfs   map[string]string
+
+	DNS        []string
+	ExtraHosts []string
+	LogConfig  container.LogConfig
+
+	// Publish maps the exposed ports to random ports of the Docker host,
+	// for hubs that can not reach the container network. HostAddress is
+	// where the Docker host is reached, 127.0.0.1 by default.
+	Publish     bool
+	HostAddress string
+}
+
+func BuildSessionContainer(requestID, image string, opts *SessionOptions) (*container.Config, *container.HostConfig, error) {
+	if opts == nil {
+		opts = &SessionOptions{}
+	}
+	browser, version, err := blueclient.SessionBrowser(image, opts.Browser, opts.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	port := opts.Port
+	if port == 0 {
+		port = blueclient.DefaultWebDriverPort
+	}
+	exposed := nat.PortSet{}
+	exposed[nat.Port(fmt.Sprintf("%d/tcp", port))] = struct{}{}
+	if opts.EnableVNC {
+		exposed[nat.Port(fmt.Sprintf("%d/tcp", vncPort))] = struct{}{}
+	}
+
+	labels := map[string]string{
+		blueclient.ManagedLabel:   blueclient.ManagedValue,
+		blueclient.RequestIDLabel: requestID,
+		blueclient.BrowserLabel:   browser,
+		blueclient.VersionLabel:   version,
+	}
+	if opts.User != "" {
+		labels[blueclient.UserAnnotation] = opts.User
+	}
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+
+	config := &container.Config{
+		Image:        image,
+		Env:          envList(opts.settings().Environment()),
+		ExposedPorts: exposed,
+		Labels:       labels,
+	}
+
+	shmSize := opts.ShmSize
+	if shmSize == 0 {
+		shmSize = defaultShmSize
+	}
+	hostConfig := &container.HostConfig{
+		ShmSize:    shmSize,
+		Tmpfs:      opts.Tmpfs,
+		DNS:        opts.DNS,
+		ExtraHosts: opts.ExtraHosts,
+		LogConfig:  opts.LogConfig,
+		Resources: container.Resources{
+			Memory:   opts.Memory,
+			NanoCPUs: int64(opts.CPU * 1e9),
+		},
+	}
+	if opts.Publish {
+		hostConfig.PortBindings = nat.PortMap{}
+		for p := range exposed {
+			hostConfig.PortBindings[p] = []nat.PortBinding{{HostIP: "0.0.0.0"}}
+		}
+	}
+	return config, hostConfig, nil
+}
+
+// MatchedSessionOptions returns the options of a session for the
+// capabilities matched in the catalog, the image is m.Browser.Image.
+func MatchedSessionOptions(m *capabilities.Match) *SessionOptions {
+	settings := m.Settings()
+	return &SessionOptions{
+		Labels:           settings.Labels,
+		Browser:          settings.Browser,
+		Version:          settings.Version,
+		Port:             settings.Port,
+		Path:             settings.Path,
+		Env:              settings.Env,
+		ScreenResolution: settings.ScreenResolution,
+		TimeZone:         settings.TimeZone,
+		EnableVNC:        settings.EnableVNC,
+		Tmpfs:            m.Browser.Tmpfs,
+	}
+}
+
+func (opts *SessionOptions) settings() blueclient.SessionSettings {
+	return blueclient.SessionSettings{
+		Labels:           opts.Labels,
+		Browser:          opts.Browser,
+		Version:          opts.Version,
+		Port:             opts.Port,
+		Path:             opts.Path,
+		Env:              opts.Env,
+		ScreenResolution: opts.ScreenResolution,
+		TimeZone:         opts.TimeZone,
+		EnableVNC:        opts.EnableVNC,
+	}
+}
+
+func envList(env map[string]string) []string {
+	var vars []string
+	for k, v := range env {
+		vars = append(vars, k+"="+v)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// CreateSessionContainer creates and starts a session container and returns
+// once its WebDriver answers the probe. A container that does not get there
+// is removed.
+func (d *DockerClient) CreateSessionContainer(ctx context.Context, requestID, image string, opts *SessionOptions, probe blueclient.Probe) (string, *blueclient.Endpoint, error) {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_CREATE_SESSION] [REQUEST: %s] [IMAGE: %s]", 0, requestID, image)
+	}
+	if opts == nil {
+		opts = &SessionOptions{}
+	}
+	config, hostConfig, err := BuildSessionContainer(requestID, image, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	created, err := d.Client.ContainerCreate(ctx, config, hostConfig, nil, "")
+	if err != nil {
+		return "", nil, err
+	}
+	d.useImage(image, time.Now())
+	id := created.ID
+
+	ep, err := d.startSession(ctx, id, opts, probe)
+	if err != nil {
+		if d.debug {
+			log.Printf("[%d] [DOCKER_SESSION_FAILED] [ID: %s] [ERROR: %v]", 0, id, err)
+		}
+		if removeErr := d.RemoveSession(context.Background(), id); removeErr != nil {
+			log.Printf("WARNING: cannot remove failed session container %s: %s", id, removeErr)
+		}
+		return "", nil, err
+	}
+	return id, ep, nil
+}
+
+func (d *DockerClient) startSession(ctx context.Context, id string, opts *SessionOptions, probe blueclient.Probe) (*blueclient.Endpoint, error) {
+	if err := d.Client.ContainerStart(ctx, id, types.ContainerStartOptions{}); err != nil {
+		return nil, err
+	}
+	ep, err := d.WaitReady(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Publish {
+		info, err := d.Client.ContainerInspect(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		ep = publishedEndpoint(&info, opts.HostAddress)
+	}
+	if err := opts.settings().Probe(probe).Wait(ctx, ep); err != nil {
+		return nil, err
+	}
+	return ep, nil
+}
+
+// publishedEndpoint maps the exposed ports to the ports they are published
+// on at the Docker host.
+func publishedEndpoint(info *types.ContainerJSON, hostAddress string) *blueclient.Endpoint {
+	if hostAddress == "" {
+		hostAddress = localAddress
+	}
+	ep := &blueclient.Endpoint{IP: hostAddress, Ports: make(map[int]int)}
+	if info.NetworkSettings == nil {
+		return ep
+	}
+	for port, bindings := range info.NetworkSettings.Ports {
+		for _, binding := range bindings {
+			if hostPort, err := strconv.Atoi(binding.HostPort); err == nil {
+				ep.Ports[port.Int()] = hostPort
+				break
+			}
+		}
+	}
+	return ep
+}
@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+	blueclient "github.com/kolobok01/util/client"
+	"github.com/kolobok01/util/client/capabilities"
+)
+
+func TestBuildSessionContainer(t *testing.T) {
+	config, hostConfig, err := BuildSessionContainer("42", "blueio/images:chrome_70.0", &SessionOptions{
+		User:             "alice",
+		ScreenResolution: "1920x1080x24",
+		EnableVNC:        true,
+		Memory:           1 << 30,
+		CPU:              1.5,
+		ExtraHosts:       []string{"hub:10.0.0.1"},
+		Publish:          true,
+	})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, config.Labels, EqualTo{map[string]string{
+		blueclient.ManagedLabel:   blueclient.ManagedValue,
+		blueclient.RequestIDLabel: "42",
+		blueclient.BrowserLabel:   "chrome",
+		blueclient.VersionLabel:   "70.0",
+		blueclient.UserAnnotation: "alice",
+	}})
+	AssertThat(t, config.Env, EqualTo{[]string{"ENABLE_VNC=true", "SCREEN_RESOLUTION=1920x1080x24"}})
+	AssertThat(t, config.ExposedPorts, EqualTo{nat.PortSet{"4444/tcp": {}, "5900/tcp": {}}})
+	AssertThat(t, hostConfig.ShmSize, EqualTo{int64(defaultShmSize)})
+	AssertThat(t, hostConfig.NanoCPUs, EqualTo{int64(1500000000)})
+	AssertThat(t, hostConfig.ExtraHosts, EqualTo{[]string{"hub:10.0.0.1"}})
+	AssertThat(t, len(hostConfig.PortBindings), EqualTo{2})
+}
+
+func TestBuildSessionContainerBadImage(t *testing.T) {
+	_, _, err := BuildSessionContainer("42", "chrome", nil)
+	AssertThat(t, err, Not{nil})
+}
+
+func TestPublishedEndpoint(t *testing.T) {
+	info := &types.ContainerJSON{NetworkSettings: &types.NetworkSettings{}}
+	info.NetworkSettings.Ports = nat.PortMap{"4444/tcp": {{HostIP: "0.0.0.0", HostPort: "32768"}}}
+	ep := publishedEndpoint(info, "")
+	AssertThat(t, ep.Address(4444), EqualTo{"127.0.0.1:32768"})
+}
+
+func TestMatchedSessionOptions(t *testing.T) {
+	m := &capabilities.Match{
+		Capabilities: capabilities.Capabilities{Options: capabilities.Options{EnableVNC: true, Env: []string{"TZ=UTC"}}},
+		Browser: &blueclient.ResolvedBrowser{Browser: "firefox", Version: "63.0", BrowserVersion: &blueclient.BrowserVersion{
+			Image: "selenoid/firefox:63.0",
+			Port:  "4445",
+			Path:  "/wd/hub",
+			Tmpfs: map[string]string{"/tmp": "size=128m"},
+			Env:   []string{"LANG=en_US.UTF-8"},
+		}},
+	}
+	opts := MatchedSessionOptions(m)
+	AssertThat(t, opts.Port, EqualTo{4445})
+	AssertThat(t, opts.EnableVNC, Is{true})
+	AssertThat(t, opts.Tmpfs, EqualTo{map[string]string{"/tmp": "size=128m"}})
+	AssertThat(t, opts.Env, EqualTo{map[string]string{"LANG": "en_US.UTF-8", "TZ": "UTC"}})
+
+	probe := opts.settings().Probe(blueclient.Probe{})
+	AssertThat(t, probe.Port, EqualTo{4445})
+	AssertThat(t, probe.Path, EqualTo{"/wd/hub/status"})
+
+	config, _, err := BuildSessionContainer("42", m.Browser.Image, opts)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, config.Labels[blueclient.BrowserLabel], EqualTo{"firefox"})
+	AssertThat(t, config.Labels[blueclient.VersionLabel], EqualTo{"63.0"})
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	blueclient "github.com/kolobok01/util/client"
 
@@ -21,61 +22,74 @@ import (
 const (
 	dockerApiVersion = "DOCKER_API_VERSION"
 	dockerClientName = "DOCKER"
+	dockerHost       = "DOCKER_HOST"
+	dockerSocket     = "/var/run/docker.sock"
 )
 
+func init() {
+	blueclient.Register(blueclient.Backend{
+		Type:     blueclient.DockerType,
+		Priority: 20,
+		Detect:   detect,
+		New:      newFromOptions,
+	})
+}
+
 type DockerClient struct {
 	Type   string
 	Client *client.Client
 	debug  bool
 	mu     sync.Mutex
+	// last pull or use of the images by reference, see usedImage
+	imagesUsed map[string]time.Time
 }
 
+// CreateCompatibleClient creates a client from the environment like the
+// docker CLI. The API version is taken from DOCKER_API_VERSION or negotiated
+// with the daemon, the default version is used when the daemon can not be
+// reached.
 func CreateCompatibleClient(onVersionSpecified, onVersionDetermined, onUsingDefaultVersion func(string)) (*DockerClient, error) {
-	dockerApiVersionEnv := os.Getenv(dockerApiVersion)
-	if dockerApiVersionEnv != "" {
-		onVersionSpecified(dockerApiVersionEnv)
-	} else {
-		maxMajorVersion, maxMinorVersion := parseVersion(api.DefaultVersion)
-		minMajorVersion, minMinorVersion := parseVersion(api.MinVersion)
-		for majorVersion := maxMajorVersion; majorVersion >= minMajorVersion; majorVersion-- {
-			for minorVersion := maxMinorVersion; minorVersion >= minMinorVersion; minorVersion-- {
-				apiVersion := fmt.Sprintf("%d.%d", majorVersion, minorVersion)
-				os.Setenv(dockerApiVersion, apiVersion)
-				docker, err := client.NewEnvClient()
-				if err != nil {
-					return nil, err
-				}
-				if isAPIVersionCorrect(docker) {
-					onVersionDetermined(apiVersion)
-					return &DockerClient{
-						Type:   blueclient.DockerType,
-						Client: docker,
-					}, nil
-				}
-				docker.Close()
-			}
-		}
+	opts := EnvOptions()
+	d, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.APIVersion != "" {
+		onVersionSpecified(opts.APIVersion)
+		return d, nil
+	}
+	if err := d.negotiateVersion(context.Background()); err != nil {
 		onUsingDefaultVersion(api.DefaultVersion)
+		return d, nil
 	}
-	cl, err := client.NewEnvClient()
-	return &DockerClient{
-		Type:   blueclient.DockerType,
-		Client: cl,
-	}, err
+	onVersionDetermined(d.Client.ClientVersion())
+	return d, nil
 }
 
-func isAPIVersionCorrect(docker *client.Client) bool {
-	ctx := context.Background()
-	apiInfo, err := docker.ServerVersion(ctx)
+func detect(ctx context.Context) error {
+	if os.Getenv(dockerHost) != "" {
+		return nil
+	}
+	if _, err := os.Stat(dockerSocket); err != nil {
+		return fmt.Errorf("%s is not set and %s is not available: %v", dockerHost, dockerSocket, err)
+	}
+	return nil
+}
+
+func newFromOptions(ctx context.Context, opts blueclient.Options) (blueclient.Client, error) {
+	cli, err := NewClient(ctx, EnvOptions(), opts.OnVersion)
 	if err != nil {
-		return false
+		return nil, err
 	}
-	return apiInfo.APIVersion == docker.ClientVersion()
+	return cli, nil
 }
 
 func parseVersion(ver string) (int, int) {
 	const point = "."
 	pieces := strings.Split(ver, point)
+	if len(pieces) != 2 {
+		return 0, 0
+	}
 	major, err := strconv.Atoi(pieces[0])
 	if err != nil {
 		return 0, 0
@@ -102,11 +116,27 @@ func (d *DockerClient) GetLogs(ctx context.Context, id string) (io.ReadCloser, e
 	return d.Client.ContainerLogs(ctx, id, options)
 }
 
+func (d *DockerClient) CopyTo(ctx context.Context, id, dstPath string, content io.Reader) error {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_COPY_TO] [ID: %s] [PATH: %s]", 0, id, dstPath)
+	}
+	options := types.CopyToContainerOptions{}
+	return d.Client.CopyToContainer(ctx, id, dstPath, content, options)
+}
+
+func (d *DockerClient) CopyFrom(ctx context.Context, id, srcPath string) (io.ReadCloser, error) {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_COPY_FROM] [ID: %s] [PATH: %s]", 0, id, srcPath)
+	}
+	content, _, err := d.Client.CopyFromContainer(ctx, id, srcPath)
+	return content, err
+}
+
 func (d *DockerClient) SetDebug(debug bool) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	d.debug = debug
 	if d.debug {
-		log.Printf("[%d] [DOCKER_DEBUG] [DEBUG: %s]", 0, d.debug)
+		log.Printf("[%d] [DOCKER_DEBUG] [DEBUG: %t]", 0, d.debug)
 	}
 }
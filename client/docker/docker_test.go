@@ -1,20 +1,30 @@
 package docker
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	. "github.com/aandryashin/matchers"
 	"github.com/docker/docker/api"
+	"github.com/docker/docker/client"
 	"github.com/kolobok01/util"
+	blueclient "github.com/kolobok01/util/client"
 )
 
 var (
 	mockDockerServer *httptest.Server
 	apiVersion       = "1.29"
+	archive          = new(bytes.Buffer)
 )
 
 func init() {
@@ -47,9 +57,129 @@ func mux() http.Handler {
 			w.Write([]byte(output))
 		},
 	))
+	mux.HandleFunc("/_ping", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("API-Version", apiVersion)
+			w.Write([]byte("OK"))
+		},
+	))
+	mux.HandleFunc(fmt.Sprintf("/v%s/containers/test-container/archive", apiVersion), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPut:
+				archive.Reset()
+				archive.ReadFrom(r.Body)
+			case http.MethodGet:
+				stat := fmt.Sprintf(`{"name":"%s","size":%d,"mode":420}`, r.URL.Query().Get("path"), archive.Len())
+				w.Header().Set("X-Docker-Container-Path-Stat", base64.StdEncoding.EncodeToString([]byte(stat)))
+				w.Write(archive.Bytes())
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		},
+	))
+	mux.HandleFunc(fmt.Sprintf("/v%s/events", apiVersion), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			output := `
+				{"Type":"container","Action":"create","Actor":{"ID":"test-container","Attributes":{"name":"test","app":"blueio"}},"time":1500000000}
+				{"Type":"container","Action":"exec_start: ls","Actor":{"ID":"test-container","Attributes":{}},"time":1500000001}
+				{"Type":"container","Action":"die","Actor":{"ID":"test-container","Attributes":{"name":"test","exitCode":"137","app":"blueio"}},"time":1500000002}
+			`
+			w.Write([]byte(output))
+		},
+	))
+	mux.HandleFunc(fmt.Sprintf("/v%s/containers/test-container/stats", apiVersion), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			output := `
+				{
+					"read": "2017-03-23T10:00:00Z",
+					"cpu_stats": {"cpu_usage": {"total_usage": 300, "percpu_usage": [150, 150]}, "system_cpu_usage": 2000},
+					"precpu_stats": {"cpu_usage": {"total_usage": 100}, "system_cpu_usage": 1000},
+					"memory_stats": {"usage": 1024, "limit": 4096},
+					"networks": {"eth0": {"rx_bytes": 10, "tx_bytes": 20}, "eth1": {"rx_bytes": 1, "tx_bytes": 2}}
+				}
+			`
+			w.Write([]byte(output))
+		},
+	))
+	mux.HandleFunc(fmt.Sprintf("/v%s/containers/test-container/json", apiVersion), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			output := `
+				{
+					"Id": "test-container",
+					"State": {"Status": "running", "Running": true},
+					"Config": {"ExposedPorts": {"4444/tcp": {}}},
+					"NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2"}}}
+				}
+			`
+			w.Write([]byte(output))
+		},
+	))
+	mux.HandleFunc(fmt.Sprintf("/v%s/containers/exited-container/json", apiVersion), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			output := `
+				{
+					"Id": "exited-container",
+					"State": {"Status": "exited", "Running": false, "ExitCode": 1}
+				}
+			`
+			w.Write([]byte(output))
+		},
+	))
+	mux.HandleFunc(fmt.Sprintf("/v%s/containers/json", apiVersion), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			output := `
+				[{
+					"Id": "session-container",
+					"Created": 1546398245,
+					"State": "exited",
+					"Labels": {"blueio": "true", "blueio.request-id": "42", "blueio.browser": "chrome", "blueio.version": "70.0"}
+				}]
+			`
+			w.Write([]byte(output))
+		},
+	))
+	mux.HandleFunc(fmt.Sprintf("/v%s/containers/session-container", apiVersion), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		},
+	))
+	mux.HandleFunc(fmt.Sprintf("/v%s/networks/create", apiVersion), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id": "session-network"}`))
+		},
+	))
+	mux.HandleFunc(fmt.Sprintf("/v%s/networks/blueio-test-container/connect", apiVersion), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	))
+	mux.HandleFunc(fmt.Sprintf("/v%s/networks/bridge/disconnect", apiVersion), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	))
+	mux.HandleFunc(fmt.Sprintf("/v%s/networks/", apiVersion), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "network not found"}`))
+		},
+	))
 	return mux
 }
 
+func testClient(t *testing.T) *DockerClient {
+	cli, err := client.NewClient("tcp://"+util.HostPort(mockDockerServer.URL), apiVersion, nil, nil)
+	AssertThat(t, err, Is{nil})
+	return &DockerClient{Type: blueclient.DockerType, Client: cli}
+}
+
 func TestCreateCompatibleDockerClient(t *testing.T) {
 	testCreateCompatibleDockerClient(t, "1.27")
 }
@@ -97,3 +227,210 @@ func TestParseIncorrectMinorVersion(t *testing.T) {
 	AssertThat(t, major, EqualTo{0})
 	AssertThat(t, minor, EqualTo{0})
 }
+
+func TestCopyToAndFrom(t *testing.T) {
+	cli := testClient(t)
+	content, err := blueclient.BytesToTar("file.txt", []byte("some-data"))
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, cli.CopyTo(context.Background(), "test-container", "/tmp", content), Is{nil})
+
+	r, err := cli.CopyFrom(context.Background(), "test-container", "/tmp/file.txt")
+	AssertThat(t, err, Is{nil})
+	defer r.Close()
+	name, data, err := blueclient.TarToBytes(r)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, name, EqualTo{"file.txt"})
+	AssertThat(t, string(data), EqualTo{"some-data"})
+}
+
+func TestCopyFromMissingContainer(t *testing.T) {
+	_, err := testClient(t).CopyFrom(context.Background(), "missing", "/tmp/file.txt")
+	AssertThat(t, err, Not{nil})
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := testClient(t).Watch(ctx, map[string]string{"app": "blueio"})
+	AssertThat(t, err, Is{nil})
+
+	var received []blueclient.Event
+	for event := range events {
+		received = append(received, event)
+	}
+	AssertThat(t, len(received), EqualTo{2})
+	AssertThat(t, received[0].Type, EqualTo{blueclient.EventCreated})
+	AssertThat(t, received[0].Name, EqualTo{"test"})
+	AssertThat(t, received[0].Labels, EqualTo{map[string]string{"app": "blueio"}})
+	AssertThat(t, received[1].Type, EqualTo{blueclient.EventExited})
+	AssertThat(t, received[1].ExitCode, EqualTo{137})
+}
+
+func TestStats(t *testing.T) {
+	stats, err := testClient(t).Stats(context.Background(), "test-container", false)
+	AssertThat(t, err, Is{nil})
+	s := <-stats
+	AssertThat(t, s.CPUPercent, EqualTo{40.0})
+	AssertThat(t, s.MemoryUsage, EqualTo{uint64(1024)})
+	AssertThat(t, s.MemoryLimit, EqualTo{uint64(4096)})
+	AssertThat(t, s.NetworkRx, EqualTo{uint64(11)})
+	AssertThat(t, s.NetworkTx, EqualTo{uint64(22)})
+	_, ok := <-stats
+	AssertThat(t, ok, Is{false})
+}
+
+func TestNewFromRegistry(t *testing.T) {
+	os.Unsetenv("DOCKER_API_VERSION")
+	defer os.Unsetenv("DOCKER_API_VERSION")
+	var version string
+	cli, err := blueclient.New(context.Background(), blueclient.Options{
+		OnVersion: func(v string) {
+			version = v
+		},
+	})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, cli.GetType(), EqualTo{blueclient.DockerType})
+	AssertThat(t, version, EqualTo{"1.27"})
+}
+
+func TestWaitReady(t *testing.T) {
+	ep, err := testClient(t).WaitReady(context.Background(), "test-container")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, ep.Address(4444), EqualTo{"172.17.0.2:4444"})
+}
+
+func TestWaitReadyExited(t *testing.T) {
+	_, err := testClient(t).WaitReady(context.Background(), "exited-container")
+	AssertThat(t, err.Error(), EqualTo{"exited-container will not become ready: exited: exit code 1"})
+}
+
+func TestListSessions(t *testing.T) {
+	sessions, err := testClient(t).ListSessions(context.Background())
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, sessions, EqualTo{[]blueclient.Session{{
+		ID:        "session-container",
+		RequestID: "42",
+		Browser:   "chrome",
+		Version:   "70.0",
+		State:     "exited",
+		Finished:  true,
+		Created:   time.Unix(1546398245, 0),
+	}}})
+}
+
+var _ blueclient.Isolator = &DockerClient{}
+
+// isolationServer serves the container "test" whose full ID is
+// "test-container", it is connected to the networks it is isolated to and
+// published when bindings are given.
+func isolationServer(bindings string) (*httptest.Server, *sync.Mutex, map[string]string) {
+	var mu sync.Mutex
+	networks := map[string]string{defaultNetwork: "172.17.0.2"}
+	inspect := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var settings []string
+		for name, ip := range networks {
+			settings = append(settings, fmt.Sprintf(`"%s": {"IPAddress": "%s"}`, name, ip))
+		}
+		fmt.Fprintf(w, `{"Id": "test-container", "Config": {"ExposedPorts": {"4444/tcp": {}}},
+			"HostConfig": {"PortBindings": {%s}}, "NetworkSettings": {"Networks": {%s}}}`,
+			bindings, strings.Join(settings, ", "))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(fmt.Sprintf("/v%s/containers/test/json", apiVersion), inspect)
+	mux.HandleFunc(fmt.Sprintf("/v%s/containers/test-container/json", apiVersion), inspect)
+	mux.HandleFunc(fmt.Sprintf("/v%s/networks/create", apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id": "session-network"}`))
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/networks/blueio-test-container/connect", apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Container string }
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Container == "missing-hub" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "no such container"}`))
+			return
+		}
+		if body.Container == "test-container" {
+			mu.Lock()
+			networks["blueio-test-container"] = "172.18.0.2"
+			mu.Unlock()
+		}
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/networks/bridge/disconnect", apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		delete(networks, defaultNetwork)
+		mu.Unlock()
+	})
+	return httptest.NewServer(mux), &mu, networks
+}
+
+func isolationClient(t *testing.T, srv *httptest.Server) *DockerClient {
+	cli, err := client.NewClient("tcp://"+util.HostPort(srv.URL), apiVersion, nil, nil)
+	AssertThat(t, err, Is{nil})
+	return &DockerClient{Type: blueclient.DockerType, Client: cli}
+}
+
+func TestIsolate(t *testing.T) {
+	srv, mu, networks := isolationServer("")
+	defer srv.Close()
+
+	ep, err := isolationClient(t, srv).Isolate(context.Background(), "test", blueclient.IsolationPolicy{})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, ep.Address(4444), EqualTo{"172.18.0.2:4444"})
+	mu.Lock()
+	defer mu.Unlock()
+	AssertThat(t, networks, EqualTo{map[string]string{"blueio-test-container": "172.18.0.2"}})
+}
+
+func TestIsolateRejectsEgress(t *testing.T) {
+	_, err := testClient(t).Isolate(context.Background(), "test-container", blueclient.IsolationPolicy{EgressCIDRs: []string{"10.0.0.0/8"}})
+	AssertThat(t, err, Is{ErrEgressNotSupported})
+}
+
+func TestIsolateRejectsPublished(t *testing.T) {
+	srv, _, _ := isolationServer(`"4444/tcp": [{"HostIp": "0.0.0.0"}]`)
+	defer srv.Close()
+
+	_, err := isolationClient(t, srv).Isolate(context.Background(), "test", blueclient.IsolationPolicy{})
+	AssertThat(t, err, Is{ErrPublishedIsolation})
+}
+
+func TestIsolateRemovesNetworkOnFailure(t *testing.T) {
+	removed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc(fmt.Sprintf("/v%s/containers/test-container/json", apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Id": "test-container"}`))
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/networks/create", apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id": "session-network"}`))
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/networks/blueio-test-container/connect", apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Container string }
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Container == "missing-hub" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "no such container"}`))
+		}
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/networks/blueio-test-container", apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Id": "session-network", "Name": "blueio-test-container", "Containers": {}}`))
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/networks/session-network", apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		removed = r.Method == http.MethodDelete
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	_, err := isolationClient(t, srv).Isolate(context.Background(), "test-container", blueclient.IsolationPolicy{HubContainers: []string{"missing-hub"}})
+	AssertThat(t, err, Not{nil})
+	AssertThat(t, removed, Is{true})
+}
+
+func TestRemoveSessionWithoutNetwork(t *testing.T) {
+	err := testClient(t).RemoveSession(context.Background(), "session-container")
+	AssertThat(t, err, Is{nil})
+}
@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+var eventTypes = map[string]blueclient.EventType{
+	"create":                 blueclient.EventCreated,
+	"start":                  blueclient.EventStarted,
+	"health_status: healthy": blueclient.EventReady,
+	"die":                    blueclient.EventExited,
+	"oom":                    blueclient.EventOOMKilled,
+	"destroy":                blueclient.EventDeleted,
+}
+
+// attributes Docker mixes into the container labels of an event
+var eventAttributes = map[string]bool{
+	"exitCode": true,
+	"image":    true,
+	"name":     true,
+	"signal":   true,
+}
+
+func (d *DockerClient) Watch(ctx context.Context, selector map[string]string) (<-chan blueclient.Event, error) {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_WATCH] [SELECTOR: %v]", 0, selector)
+	}
+	args := filters.NewArgs()
+	args.Add("type", events.ContainerEventType)
+	for k, v := range selector {
+		args.Add("label", k+"="+v)
+	}
+	messages, errs := d.Client.Events(ctx, types.EventsOptions{Filters: args})
+
+	out := make(chan blueclient.Event)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case msg := <-messages:
+				event, ok := toEvent(msg)
+				if !ok {
+					continue
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-errs:
+				if d.debug {
+					log.Printf("[%d] [DOCKER_WATCH_STOPPED] [ERROR: %v]", 0, err)
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func toEvent(msg events.Message) (blueclient.Event, bool) {
+	eventType, ok := eventTypes[msg.Action]
+	if !ok {
+		return blueclient.Event{}, false
+	}
+	event := blueclient.Event{
+		Type:   eventType,
+		ID:     msg.Actor.ID,
+		Name:   msg.Actor.Attributes["name"],
+		Labels: make(map[string]string),
+		Time:   time.Unix(0, msg.TimeNano),
+	}
+	if msg.TimeNano == 0 {
+		event.Time = time.Unix(msg.Time, 0)
+	}
+	if code, err := strconv.Atoi(msg.Actor.Attributes["exitCode"]); err == nil {
+		event.ExitCode = code
+	}
+	for k, v := range msg.Actor.Attributes {
+		if !eventAttributes[k] {
+			event.Labels[k] = v
+		}
+	}
+	return event, true
+}
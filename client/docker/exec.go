@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/docker/docker/api/types"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+const (
+	stdoutStream = 1
+	stderrStream = 2
+)
+
+func (d *DockerClient) Exec(ctx context.Context, id string, cmd []string) (*blueclient.ExecResult, error) {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_EXEC] [ID: %s] [CMD: %v]", 0, id, cmd)
+	}
+	config := types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	exec, err := d.Client.ContainerExecCreate(ctx, id, config)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.Client.ContainerExecAttach(ctx, exec.ID, config)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if err := demux(resp.Reader, stdout, stderr); err != nil {
+		return nil, err
+	}
+	inspect, err := d.Client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &blueclient.ExecResult{
+		ExitCode: inspect.ExitCode,
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+	}, nil
+}
+
+// demux splits the multiplexed stream of a non-tty exec: every frame starts
+// with an 8 byte header holding the stream type and the big endian frame size.
+func demux(r io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var w io.Writer
+		switch header[0] {
+		case stdoutStream:
+			w = stdout
+		case stderrStream:
+			w = stderr
+		default:
+			return fmt.Errorf("unexpected stream type %d", header[0])
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package docker
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+)
+
+func frame(stream byte, data string) []byte {
+	header := []byte{stream, 0, 0, 0, 0, 0, 0, byte(len(data))}
+	return append(header, data...)
+}
+
+func TestDemux(t *testing.T) {
+	input := new(bytes.Buffer)
+	input.Write(frame(stdoutStream, "out-1 "))
+	input.Write(frame(stderrStream, "err"))
+	input.Write(frame(stdoutStream, "out-2"))
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	AssertThat(t, demux(input, stdout, stderr), Is{nil})
+	AssertThat(t, stdout.String(), EqualTo{"out-1 out-2"})
+	AssertThat(t, stderr.String(), EqualTo{"err"})
+}
+
+func TestDemuxUnknownStream(t *testing.T) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	AssertThat(t, demux(bytes.NewReader(frame(7, "x")), stdout, stderr), Not{nil})
+}
+
+func TestDemuxTruncatedFrame(t *testing.T) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	AssertThat(t, demux(bytes.NewReader(frame(stdoutStream, "data")[:10]), stdout, stderr), Not{nil})
+}
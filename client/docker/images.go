@@ -0,0 +1,183 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+// pullMessage is a line of the JSON stream returned by an image pull.
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error string `json:"error"`
+}
+
+func (d *DockerClient) PullImage(ctx context.Context, image string) (<-chan blueclient.PullProgress, error) {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_PULL_IMAGE] [IMAGE: %s]", 0, image)
+	}
+	body, err := d.Client.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return nil, err
+	}
+	d.useImage(image, time.Now())
+	out := make(chan blueclient.PullProgress)
+	go func() {
+		defer close(out)
+		defer body.Close()
+		pullProgress(ctx, image, body, out)
+	}()
+	return out, nil
+}
+
+// pullProgress converts the pull stream, a broken stream ends with an
+// error progress.
+func pullProgress(ctx context.Context, image string, r io.Reader, out chan<- blueclient.PullProgress) {
+	decoder := json.NewDecoder(r)
+	for {
+		var msg pullMessage
+		err := decoder.Decode(&msg)
+		if err == io.EOF {
+			return
+		}
+		p := blueclient.PullProgress{
+			Image:   image,
+			Layer:   msg.ID,
+			Status:  msg.Status,
+			Current: msg.ProgressDetail.Current,
+			Total:   msg.ProgressDetail.Total,
+			Error:   msg.Error,
+			Time:    time.Now(),
+		}
+		if err != nil {
+			p.Error = err.Error()
+		}
+		select {
+		case out <- p:
+		case <-ctx.Done():
+			return
+		}
+		if p.Error != "" {
+			return
+		}
+	}
+}
+
+func (d *DockerClient) ListImages(ctx context.Context) ([]blueclient.ImageInfo, error) {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_LIST_IMAGES]", 0)
+	}
+	summaries, err := d.Client.ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	containers, err := d.Client.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	for _, c := range containers {
+		used[c.ImageID] = true
+	}
+
+	images := []blueclient.ImageInfo{}
+	for _, summary := range summaries {
+		for _, tag := range summary.RepoTags {
+			info := blueclient.ImageInfo{
+				Image:   tag,
+				ID:      summary.ID,
+				Size:    summary.Size,
+				Created: time.Unix(summary.Created, 0),
+				InUse:   used[summary.ID],
+			}
+			if blueclient.BrowserImage(&info) {
+				images = append(images, info)
+			}
+		}
+	}
+	return images, nil
+}
+
+// RemoveUnusedImages untags the browser images neither pulled nor used for
+// olderThan and removes the image once its last tag is gone.
+func (d *DockerClient) RemoveUnusedImages(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_REMOVE_UNUSED_IMAGES] [OLDER_THAN: %s]", 0, olderThan)
+	}
+	images, err := d.ListImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	removed := []string{}
+	now := time.Now()
+	deadline := now.Add(-olderThan)
+	for _, image := range images {
+		if image.InUse {
+			d.useImage(image.Image, now)
+			continue
+		}
+		if d.usedImage(image.Image, now).After(deadline) {
+			continue
+		}
+		if _, err := d.Client.ImageRemove(ctx, image.Image, types.ImageRemoveOptions{PruneChildren: true}); err != nil {
+			return removed, err
+		}
+		d.forgetImage(image.Image)
+		removed = append(removed, image.Image)
+	}
+	return removed, nil
+}
+
+// imageKey normalizes the image reference like the tags of the image list,
+// i.e. the default tag is explicit.
+func imageKey(image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return image
+	}
+	return reference.FamiliarString(reference.TagNameOnly(named))
+}
+
+func (d *DockerClient) useImage(image string, t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.imagesUsed == nil {
+		d.imagesUsed = make(map[string]time.Time)
+	}
+	d.imagesUsed[imageKey(image)] = t
+}
+
+// usedImage returns the time the image was last pulled or used. The build
+// time of an image says nothing about its use, so an image the client has
+// not seen before counts as used when it is first seen.
+func (d *DockerClient) usedImage(image string, now time.Time) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.imagesUsed == nil {
+		d.imagesUsed = make(map[string]time.Time)
+	}
+	key := imageKey(image)
+	used, ok := d.imagesUsed[key]
+	if !ok {
+		used = now
+		d.imagesUsed[key] = used
+	}
+	return used
+}
+
+func (d *DockerClient) forgetImage(image string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.imagesUsed, imageKey(image))
+}
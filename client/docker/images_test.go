@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+	"github.com/docker/docker/client"
+	"github.com/kolobok01/util"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+var (
+	_ blueclient.ImageManager = &DockerClient{}
+	_ blueclient.ImagePruner  = &DockerClient{}
+	_ blueclient.ImageManager = &Pool{}
+	_ blueclient.ImagePruner  = &Pool{}
+)
+
+func TestPullProgress(t *testing.T) {
+	stream := `{"status":"Pulling from blueio/images","id":"chrome_70.0"}
+{"status":"Downloading","progressDetail":{"current":512,"total":1024},"id":"a1b2"}
+{"error":"unauthorized"}
+{"status":"never read"}`
+	out := make(chan blueclient.PullProgress, 10)
+	pullProgress(context.Background(), "blueio/images:chrome_70.0", strings.NewReader(stream), out)
+	close(out)
+
+	var progress []blueclient.PullProgress
+	for p := range out {
+		progress = append(progress, p)
+	}
+	AssertThat(t, len(progress), EqualTo{3})
+	AssertThat(t, progress[1].Layer, EqualTo{"a1b2"})
+	AssertThat(t, progress[1].Current, EqualTo{int64(512)})
+	AssertThat(t, progress[1].Total, EqualTo{int64(1024)})
+	AssertThat(t, progress[2].Error, EqualTo{"unauthorized"})
+}
+
+func TestPullProgressBrokenStream(t *testing.T) {
+	out := make(chan blueclient.PullProgress, 10)
+	pullProgress(context.Background(), "blueio/images:chrome_70.0", strings.NewReader(`{"status":`), out)
+	close(out)
+	p := <-out
+	AssertThat(t, p.Error, Not{EqualTo{""}})
+}
+
+// imageServer lists an image built long ago and no containers.
+func imageServer(removed chan<- string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(fmt.Sprintf("/v%s/images/json", apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"Id": "sha256:1", "RepoTags": ["blueio/images:chrome_70.0"], "Created": 1262304000, "Size": 1}]`))
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/containers/json", apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+	mux.HandleFunc(fmt.Sprintf("/v%s/images/", apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		removed <- strings.TrimPrefix(r.URL.Path, fmt.Sprintf("/v%s/images/", apiVersion))
+		w.Write([]byte(`[]`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestRemoveUnusedImagesByLastUse(t *testing.T) {
+	removed := make(chan string, 1)
+	srv := imageServer(removed)
+	defer srv.Close()
+	cli, err := client.NewClient("tcp://"+util.HostPort(srv.URL), apiVersion, nil, nil)
+	AssertThat(t, err, Is{nil})
+	d := &DockerClient{Type: blueclient.DockerType, Client: cli}
+
+	images, err := d.RemoveUnusedImages(context.Background(), time.Hour)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, len(images), EqualTo{0})
+
+	d.useImage("blueio/images:chrome_70.0", time.Now().Add(-2*time.Hour))
+	images, err = d.RemoveUnusedImages(context.Background(), time.Hour)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, images, EqualTo{[]string{"blueio/images:chrome_70.0"}})
+	AssertThat(t, <-removed, EqualTo{"blueio/images:chrome_70.0"})
+}
+
+func TestImageKey(t *testing.T) {
+	AssertThat(t, imageKey("blueio/images"), EqualTo{"blueio/images:latest"})
+	AssertThat(t, imageKey("docker.io/blueio/images:chrome_70.0"), EqualTo{"blueio/images:chrome_70.0"})
+}
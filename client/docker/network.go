@@ -0,0 +1,135 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+const (
+	defaultNetwork       = "bridge"
+	sessionNetworkPrefix = "blueio-"
+)
+
+func sessionNetwork(id string) string {
+	return sessionNetworkPrefix + id
+}
+
+var (
+	// ErrEgressNotSupported is returned by Isolate for policies with egress
+	// CIDRs, Docker networks can not filter by destination.
+	ErrEgressNotSupported = errors.New("docker can not restrict the egress of a session to CIDRs")
+	// ErrPublishedIsolation is returned by Isolate for published sessions,
+	// their ports are published through the default bridge the session
+	// leaves.
+	ErrPublishedIsolation = errors.New("docker can not isolate a session with published ports")
+)
+
+// Isolate moves the container from the default bridge to an internal network
+// of its own and connects the hub containers to it, the session can then only
+// reach the hub. The returned endpoint is the address of the session on that
+// network, the one it was started with is gone. Published sessions can not
+// be isolated. The network is removed again when a step fails.
+func (d *DockerClient) Isolate(ctx context.Context, id string, policy blueclient.IsolationPolicy) (*blueclient.Endpoint, error) {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_ISOLATE] [ID: %s] [POLICY: %+v]", 0, id, policy)
+	}
+	if len(policy.EgressCIDRs) > 0 {
+		return nil, ErrEgressNotSupported
+	}
+	container, err := d.Client.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if container.HostConfig != nil && len(container.HostConfig.PortBindings) > 0 {
+		return nil, ErrPublishedIsolation
+	}
+	// the network is named after the full ID for RemoveIsolation to find it
+	// whatever prefix of the ID it is given
+	id = container.ID
+	name := sessionNetwork(id)
+	_, err = d.Client.NetworkCreate(ctx, name, types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         defaultNetwork,
+		Internal:       true,
+		Labels:         blueclient.ManagedSelector(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ep, err := d.isolate(ctx, id, name, policy.HubContainers)
+	if err != nil {
+		if removeErr := d.RemoveIsolation(context.Background(), id); removeErr != nil {
+			log.Printf("WARNING: cannot remove network %s of failed isolation: %v", name, removeErr)
+		}
+		return nil, err
+	}
+	return ep, nil
+}
+
+func (d *DockerClient) isolate(ctx context.Context, id, network string, hubContainers []string) (*blueclient.Endpoint, error) {
+	for _, container := range append([]string{id}, hubContainers...) {
+		if err := d.Client.NetworkConnect(ctx, network, container, nil); err != nil {
+			return nil, err
+		}
+	}
+	container, err := d.Client.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if container.NetworkSettings == nil || container.NetworkSettings.Networks[network] == nil {
+		return nil, fmt.Errorf("container %s is not connected to %s", id, network)
+	}
+	if _, ok := container.NetworkSettings.Networks[defaultNetwork]; ok {
+		if err := d.Client.NetworkDisconnect(ctx, defaultNetwork, id, false); err != nil {
+			return nil, err
+		}
+	}
+	ep := containerEndpoint(&container)
+	ep.IP = container.NetworkSettings.Networks[network].IPAddress
+	return ep, nil
+}
+
+// containerID resolves a prefix or name of a container to its full ID, the
+// ID is kept as is when there is no such container.
+func (d *DockerClient) containerID(ctx context.Context, id string) (string, error) {
+	container, err := d.Client.ContainerInspect(ctx, id)
+	if client.IsErrNotFound(err) {
+		return id, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return container.ID, nil
+}
+
+// RemoveIsolation disconnects the hub containers and removes the session
+// network, RemoveSession does it as well.
+func (d *DockerClient) RemoveIsolation(ctx context.Context, id string) error {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_REMOVE_ISOLATION] [ID: %s]", 0, id)
+	}
+	id, err := d.containerID(ctx, id)
+	if err != nil {
+		return err
+	}
+	name := sessionNetwork(id)
+	network, err := d.Client.NetworkInspect(ctx, name)
+	if client.IsErrNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for container := range network.Containers {
+		if err := d.Client.NetworkDisconnect(ctx, network.ID, container, true); err != nil {
+			return err
+		}
+	}
+	return d.Client.NetworkRemove(ctx, network.ID)
+}
@@ -0,0 +1,193 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	blueclient "github.com/kolobok01/util/client"
+
+	"github.com/docker/docker/api"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-connections/tlsconfig"
+)
+
+const (
+	dockerCertPath  = "DOCKER_CERT_PATH"
+	dockerTLSVerify = "DOCKER_TLS_VERIFY"
+)
+
+// Options describe the daemon a DockerClient talks to, so that a process can
+// use several daemons at once. EnvOptions fills them like the docker CLI.
+type Options struct {
+	// Host defaults to the local socket.
+	Host string
+	// APIVersion pins the API version, it is negotiated with the daemon
+	// otherwise.
+	APIVersion string
+	// CertPath is a directory with ca.pem, cert.pem and key.pem.
+	CertPath string
+	// TLSVerify checks the certificate of the daemon, TLS without
+	// verification is used when only CertPath is set.
+	TLSVerify bool
+	// Timeout limits connecting to the daemon and the version negotiation,
+	// it does not limit requests such as logs or events.
+	Timeout time.Duration
+	Headers map[string]string
+}
+
+// EnvOptions reads DOCKER_HOST, DOCKER_API_VERSION, DOCKER_CERT_PATH and
+// DOCKER_TLS_VERIFY.
+func EnvOptions() Options {
+	return Options{
+		Host:       os.Getenv(dockerHost),
+		APIVersion: os.Getenv(dockerApiVersion),
+		CertPath:   os.Getenv(dockerCertPath),
+		TLSVerify:  os.Getenv(dockerTLSVerify) != "",
+	}
+}
+
+// NewClient connects to the daemon and negotiates the highest API version
+// supported by both sides, unless opts.APIVersion is set.
+func NewClient(ctx context.Context, opts Options, onVersionDetermined func(string)) (*DockerClient, error) {
+	d, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	if opts.APIVersion != "" {
+		_, err = d.Client.Ping(ctx)
+	} else {
+		err = d.negotiateVersion(ctx)
+	}
+	if err != nil {
+		d.Client.Close()
+		return nil, err
+	}
+	if onVersionDetermined != nil {
+		onVersionDetermined(d.Client.ClientVersion())
+	}
+	return d, nil
+}
+
+func newClient(opts Options) (*DockerClient, error) {
+	host := opts.Host
+	if host == "" {
+		host = client.DefaultDockerHost
+	}
+	version := opts.APIVersion
+	if version == "" {
+		version = api.DefaultVersion
+	}
+	proto, addr, _, err := client.ParseHost(host)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := opts.httpClient(proto, addr)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := client.NewClient(host, version, httpClient, opts.Headers)
+	if err != nil {
+		return nil, err
+	}
+	return &DockerClient{
+		Type:   blueclient.DockerType,
+		Client: cli,
+	}, nil
+}
+
+func (opts Options) httpClient(proto, addr string) (*http.Client, error) {
+	transport := &http.Transport{}
+	if opts.CertPath != "" || opts.TLSVerify {
+		tlsOpts := tlsconfig.Options{InsecureSkipVerify: !opts.TLSVerify}
+		if opts.CertPath != "" {
+			tlsOpts.CAFile = filepath.Join(opts.CertPath, "ca.pem")
+			tlsOpts.CertFile = filepath.Join(opts.CertPath, "cert.pem")
+			tlsOpts.KeyFile = filepath.Join(opts.CertPath, "key.pem")
+		}
+		tlsc, err := tlsconfig.Client(tlsOpts)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsc
+	}
+	if err := sockets.ConfigureTransport(transport, proto, addr); err != nil {
+		return nil, err
+	}
+	if opts.Timeout > 0 {
+		transport.TLSHandshakeTimeout = opts.Timeout
+		dialer := &net.Dialer{Timeout: opts.Timeout}
+		switch proto {
+		case "unix":
+			transport.Dial = func(_, _ string) (net.Conn, error) {
+				return dialer.Dial(proto, addr)
+			}
+		case "npipe":
+		default:
+			proxyDialer, err := sockets.DialerFromEnvironment(dialer)
+			if err != nil {
+				return nil, err
+			}
+			transport.Dial = proxyDialer.Dial
+		}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// negotiateVersion downgrades the client to the API version of the daemon
+// when the daemon is older than the client.
+func (d *DockerClient) negotiateVersion(ctx context.Context) error {
+	ping, err := d.Client.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	serverVersion := ping.APIVersion
+	if serverVersion == "" {
+		// Daemons not sending the header answer unversioned requests with
+		// their own version.
+		d.Client.UpdateClientVersion("")
+		info, err := d.Client.ServerVersion(ctx)
+		if err != nil {
+			d.Client.UpdateClientVersion(api.DefaultVersion)
+			return err
+		}
+		serverVersion = info.APIVersion
+	}
+	version, err := negotiate(serverVersion)
+	if err != nil {
+		return err
+	}
+	d.Client.UpdateClientVersion(version)
+	return nil
+}
+
+func negotiate(serverVersion string) (string, error) {
+	if compareVersions(serverVersion, api.MinVersion) < 0 {
+		return "", fmt.Errorf("docker API version %s is older than the minimum supported %s", serverVersion, api.MinVersion)
+	}
+	if compareVersions(serverVersion, api.DefaultVersion) < 0 {
+		return serverVersion, nil
+	}
+	return api.DefaultVersion, nil
+}
+
+func compareVersions(v1, v2 string) int {
+	major1, minor1 := parseVersion(v1)
+	major2, minor2 := parseVersion(v2)
+	switch {
+	case major1 != major2:
+		return major1 - major2
+	default:
+		return minor1 - minor2
+	}
+}
@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+	"github.com/docker/docker/api"
+	"github.com/kolobok01/util"
+)
+
+func TestCreateCompatibleClientKeepsEnvironment(t *testing.T) {
+	os.Unsetenv("DOCKER_API_VERSION")
+	fn := func(string) {}
+	_, err := CreateCompatibleClient(fn, fn, fn)
+	AssertThat(t, err, Is{nil})
+	_, set := os.LookupEnv("DOCKER_API_VERSION")
+	AssertThat(t, set, Is{false})
+}
+
+func TestNewClientWithHost(t *testing.T) {
+	var version string
+	cli, err := NewClient(context.Background(), Options{
+		Host:    "tcp://" + util.HostPort(mockDockerServer.URL),
+		Timeout: time.Second,
+	}, func(v string) {
+		version = v
+	})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, version, EqualTo{api.DefaultVersion})
+	AssertThat(t, cli.Client.ClientVersion(), EqualTo{api.DefaultVersion})
+}
+
+func TestNewClientUnreachable(t *testing.T) {
+	_, err := NewClient(context.Background(), Options{
+		Host:    "tcp://127.0.0.1:1",
+		Timeout: time.Second,
+	}, nil)
+	AssertThat(t, err, Not{nil})
+}
+
+func TestNegotiate(t *testing.T) {
+	version, err := negotiate("1.30")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, version, EqualTo{api.DefaultVersion})
+
+	version, err = negotiate("1.24")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, version, EqualTo{"1.24"})
+
+	version, err = negotiate("2.3")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, version, EqualTo{api.DefaultVersion})
+
+	_, err = negotiate("1.11")
+	AssertThat(t, err, Not{nil})
+}
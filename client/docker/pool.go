@@ -0,0 +1,634 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	blueclient "github.com/kolobok01/util/client"
+)
+
+const (
+	defaultHealthInterval = 10 * time.Second
+	defaultHealthTimeout  = 5 * time.Second
+)
+
+// ErrNoCapacity is returned when no healthy host of the pool has a free slot.
+var ErrNoCapacity = errors.New("no healthy docker host with free capacity")
+
+// HostsError reports the hosts of the pool a call failed on by name.
+type HostsError struct {
+	Op     string
+	Errors map[string]error
+}
+
+func (e *HostsError) Error() string {
+	var names []string
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var msgs []string
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s of %s: %v", e.Op, name, e.Errors[name]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type PoolHost struct {
+	// Name identifies the host in the pool, Options.Host by default.
+	Name    string
+	Options Options
+	// Capacity is the number of sessions the host runs at once, zero means
+	// unlimited.
+	Capacity int
+}
+
+type PoolOptions struct {
+	// HealthInterval between the health checks and session refreshes of
+	// Run, ten seconds by default.
+	HealthInterval time.Duration
+	// HealthTimeout bounds every ping of a health check, five seconds by
+	// default. Hosts not answering in time are unhealthy.
+	HealthTimeout time.Duration
+}
+
+// HostStatus is the state of a host of the pool.
+type HostStatus struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+	Used     int    `json:"used"`
+	Healthy  bool   `json:"healthy"`
+	Error    string `json:"error,omitempty"`
+}
+
+type poolHost struct {
+	name     string
+	client   *DockerClient
+	capacity int
+	// negotiate is set until the API version was negotiated with the host.
+	negotiate bool
+	healthy   bool
+	err       error
+	// sessions count against the capacity, reserved are being created.
+	sessions map[string]bool
+	reserved int
+	// listing counts the running ListSessions of the host, changed are the
+	// sessions created or removed meanwhile, which its result may miss.
+	listing int
+	changed map[string]bool
+}
+
+func (h *poolHost) touch(id string) {
+	if h.listing > 0 {
+		h.changed[id] = true
+	}
+}
+
+func (h *poolHost) used() int {
+	return len(h.sessions) + h.reserved
+}
+
+func (h *poolHost) free() bool {
+	return h.healthy && (h.capacity == 0 || h.used() < h.capacity)
+}
+
+// load compares hosts of different capacities, unlimited hosts count as
+// empty ones.
+func (h *poolHost) load() float64 {
+	if h.capacity == 0 {
+		return 0
+	}
+	return float64(h.used()) / float64(h.capacity)
+}
+
+// Pool spreads sessions over several Docker hosts. New sessions go to the
+// least loaded healthy host and every other call goes to the host owning the
+// container.
+type Pool struct {
+	hosts          []*poolHost
+	owners         map[string]*poolHost
+	healthInterval time.Duration
+	healthTimeout  time.Duration
+	debug          bool
+	mu             sync.Mutex
+}
+
+// NewPool creates a client per host, checks their health and learns the
+// sessions they already run. Unreachable hosts stay in the pool as unhealthy.
+func NewPool(ctx context.Context, hosts []PoolHost, opts PoolOptions) (*Pool, error) {
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = defaultHealthInterval
+	}
+	if opts.HealthTimeout <= 0 {
+		opts.HealthTimeout = defaultHealthTimeout
+	}
+	p := &Pool{
+		owners:         make(map[string]*poolHost),
+		healthInterval: opts.HealthInterval,
+		healthTimeout:  opts.HealthTimeout,
+	}
+	for _, host := range hosts {
+		d, err := newClient(host.Options)
+		if err != nil {
+			return nil, err
+		}
+		name := host.Name
+		if name == "" {
+			name = host.Options.Host
+		}
+		if err := p.add(name, d, host.Capacity, host.Options.APIVersion == ""); err != nil {
+			return nil, err
+		}
+	}
+	p.CheckHealth(ctx)
+	if _, err := p.ListSessions(ctx); err != nil {
+		log.Printf("WARNING: cannot list the sessions of the pool: %v", err)
+	}
+	return p, nil
+}
+
+// Add puts an existing client into the pool, it is healthy until the next
+// health check.
+func (p *Pool) Add(name string, d *DockerClient, capacity int) error {
+	return p.add(name, d, capacity, false)
+}
+
+func (p *Pool) add(name string, d *DockerClient, capacity int, negotiate bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, h := range p.hosts {
+		if h.name == name {
+			return fmt.Errorf("docker host %s is already in the pool", name)
+		}
+	}
+	p.hosts = append(p.hosts, &poolHost{
+		name:      name,
+		client:    d,
+		capacity:  capacity,
+		negotiate: negotiate,
+		healthy:   true,
+		sessions:  make(map[string]bool),
+	})
+	return nil
+}
+
+// CheckHealth pings every host once, each ping within the health timeout.
+func (p *Pool) CheckHealth(ctx context.Context) {
+	p.mu.Lock()
+	hosts := append([]*poolHost(nil), p.hosts...)
+	timeout := p.healthTimeout
+	p.mu.Unlock()
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(h *poolHost) {
+			defer wg.Done()
+			p.mu.Lock()
+			negotiate := h.negotiate
+			p.mu.Unlock()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			var err error
+			if negotiate {
+				err = h.client.negotiateVersion(ctx)
+			} else {
+				_, err = h.client.Client.Ping(ctx)
+			}
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if err == nil {
+				h.negotiate = false
+			}
+			if p.debug && (err == nil) != h.healthy {
+				log.Printf("[%d] [DOCKER_POOL_HEALTH] [HOST: %s] [HEALTHY: %t] [ERROR: %v]", 0, h.name, err == nil, err)
+			}
+			h.healthy, h.err = err == nil, err
+		}(h)
+	}
+	wg.Wait()
+}
+
+// Run checks the health of the hosts and refreshes their sessions
+// periodically until ctx is done, so that sessions that finished or were
+// removed behind the back of the pool stop counting against the capacity.
+func (p *Pool) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.healthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.CheckHealth(ctx)
+			if _, err := p.ListSessions(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("WARNING: cannot refresh the sessions of the pool: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Pool) Hosts() []HostStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var hosts []HostStatus
+	for _, h := range p.hosts {
+		status := HostStatus{
+			Name:     h.name,
+			Capacity: h.capacity,
+			Used:     h.used(),
+			Healthy:  h.healthy,
+		}
+		if h.err != nil {
+			status.Error = h.err.Error()
+		}
+		hosts = append(hosts, status)
+	}
+	return hosts
+}
+
+// Host returns the name of the host owning the container.
+func (p *Pool) Host(id string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h, ok := p.owners[id]
+	if !ok {
+		return "", false
+	}
+	return h.name, true
+}
+
+// reserve takes a slot of the least loaded healthy host, the first one wins
+// a tie.
+func (p *Pool) reserve() (*poolHost, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var best *poolHost
+	for _, h := range p.hosts {
+		if h.free() && (best == nil || h.load() < best.load()) {
+			best = h
+		}
+	}
+	if best == nil {
+		return nil, ErrNoCapacity
+	}
+	best.reserved++
+	return best, nil
+}
+
+func (p *Pool) release(h *poolHost, id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h.reserved--
+	if id != "" {
+		h.sessions[id] = true
+		p.owners[id] = h
+		h.touch(id)
+	}
+}
+
+func (p *Pool) owner(id string) (*DockerClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h, ok := p.owners[id]
+	if !ok {
+		return nil, fmt.Errorf("container %s is not on any host of the pool", id)
+	}
+	return h.client, nil
+}
+
+func (p *Pool) forget(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h, ok := p.owners[id]; ok {
+		delete(h.sessions, id)
+		delete(p.owners, id)
+		h.touch(id)
+	}
+}
+
+// CreateSessionContainer creates the session on the least loaded healthy
+// host.
+func (p *Pool) CreateSessionContainer(ctx context.Context, requestID, image string, opts *SessionOptions, probe blueclient.Probe) (string, *blueclient.Endpoint, error) {
+	h, err := p.reserve()
+	if err != nil {
+		return "", nil, err
+	}
+	p.mu.Lock()
+	debug := p.debug
+	p.mu.Unlock()
+	if debug {
+		log.Printf("[%d] [DOCKER_POOL_PLACE] [REQUEST: %s] [HOST: %s]", 0, requestID, h.name)
+	}
+	id, ep, err := h.client.CreateSessionContainer(ctx, requestID, image, opts, probe)
+	p.release(h, id)
+	return id, ep, err
+}
+
+// ListSessions lists the sessions of every healthy host and refreshes which
+// host owns which container. Finished sessions do not count against the
+// capacity. Hosts that fail to list are reported in a *HostsError, returned
+// along with the sessions of the other hosts.
+func (p *Pool) ListSessions(ctx context.Context) ([]blueclient.Session, error) {
+	var sessions []blueclient.Session
+	failed := make(map[string]error)
+	for _, h := range p.healthyHosts() {
+		p.startListing(h)
+		hostSessions, err := h.client.ListSessions(ctx)
+		p.finishListing(h, hostSessions, err == nil)
+		if err != nil {
+			failed[h.name] = err
+			continue
+		}
+		sessions = append(sessions, hostSessions...)
+	}
+	if len(failed) > 0 {
+		return sessions, &HostsError{Op: "list sessions", Errors: failed}
+	}
+	return sessions, nil
+}
+
+func (p *Pool) startListing(h *poolHost) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h.listing == 0 {
+		h.changed = make(map[string]bool)
+	}
+	h.listing++
+}
+
+// finishListing merges the listed sessions into the state of the host. The
+// sessions created or removed while the list ran are kept as they are.
+func (p *Pool) finishListing(h *poolHost, listed []blueclient.Session, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	changed := h.changed
+	h.listing--
+	if h.listing == 0 {
+		h.changed = nil
+	}
+	if !ok {
+		return
+	}
+
+	running := make(map[string]bool)
+	for _, s := range listed {
+		if !changed[s.ID] {
+			running[s.ID] = !s.Finished
+		}
+	}
+	for id, owner := range p.owners {
+		if _, ok := running[id]; owner == h && !ok && !changed[id] {
+			delete(p.owners, id)
+			delete(h.sessions, id)
+		}
+	}
+	for id, isRunning := range running {
+		p.owners[id] = h
+		if isRunning {
+			h.sessions[id] = true
+		} else {
+			delete(h.sessions, id)
+		}
+	}
+}
+
+func (p *Pool) RemoveSession(ctx context.Context, id string) error {
+	d, err := p.owner(id)
+	if err != nil {
+		return err
+	}
+	if err := d.RemoveSession(ctx, id); err != nil {
+		return err
+	}
+	p.forget(id)
+	return nil
+}
+
+func (p *Pool) GetType() string {
+	return blueclient.DockerType
+}
+
+func (p *Pool) GetLogs(ctx context.Context, id string) (io.ReadCloser, error) {
+	d, err := p.owner(id)
+	if err != nil {
+		return nil, err
+	}
+	return d.GetLogs(ctx, id)
+}
+
+func (p *Pool) CopyTo(ctx context.Context, id, dstPath string, content io.Reader) error {
+	d, err := p.owner(id)
+	if err != nil {
+		return err
+	}
+	return d.CopyTo(ctx, id, dstPath, content)
+}
+
+func (p *Pool) CopyFrom(ctx context.Context, id, srcPath string) (io.ReadCloser, error) {
+	d, err := p.owner(id)
+	if err != nil {
+		return nil, err
+	}
+	return d.CopyFrom(ctx, id, srcPath)
+}
+
+func (p *Pool) Exec(ctx context.Context, id string, cmd []string) (*blueclient.ExecResult, error) {
+	d, err := p.owner(id)
+	if err != nil {
+		return nil, err
+	}
+	return d.Exec(ctx, id, cmd)
+}
+
+func (p *Pool) Stats(ctx context.Context, id string, stream bool) (<-chan blueclient.Stats, error) {
+	d, err := p.owner(id)
+	if err != nil {
+		return nil, err
+	}
+	return d.Stats(ctx, id, stream)
+}
+
+func (p *Pool) WaitReady(ctx context.Context, id string) (*blueclient.Endpoint, error) {
+	d, err := p.owner(id)
+	if err != nil {
+		return nil, err
+	}
+	return d.WaitReady(ctx, id)
+}
+
+// Isolate connects the hub containers of the policy on the host owning the
+// session.
+func (p *Pool) Isolate(ctx context.Context, id string, policy blueclient.IsolationPolicy) (*blueclient.Endpoint, error) {
+	d, err := p.owner(id)
+	if err != nil {
+		return nil, err
+	}
+	return d.Isolate(ctx, id, policy)
+}
+
+func (p *Pool) RemoveIsolation(ctx context.Context, id string) error {
+	d, err := p.owner(id)
+	if err != nil {
+		return err
+	}
+	return d.RemoveIsolation(ctx, id)
+}
+
+// Watch merges the events of the healthy hosts, hosts whose events can not
+// be watched are left out. Deleted containers are forgotten.
+func (p *Pool) Watch(ctx context.Context, selector map[string]string) (<-chan blueclient.Event, error) {
+	hosts := p.healthyHosts()
+	if len(hosts) == 0 {
+		return nil, errors.New("no healthy docker host to watch")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan blueclient.Event)
+	var wg sync.WaitGroup
+	var firstErr error
+	watched := 0
+	for _, h := range hosts {
+		events, err := h.client.Watch(ctx, selector)
+		if err != nil {
+			log.Printf("WARNING: cannot watch docker host %s: %v", h.name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("watch %s: %v", h.name, err)
+			}
+			continue
+		}
+		watched++
+		wg.Add(1)
+		go func(events <-chan blueclient.Event) {
+			defer wg.Done()
+			for event := range events {
+				if event.Type == blueclient.EventDeleted {
+					p.forget(event.ID)
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(events)
+	}
+	if watched == 0 {
+		cancel()
+		return nil, firstErr
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(out)
+	}()
+	return out, nil
+}
+
+func (p *Pool) healthyHosts() []*poolHost {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var hosts []*poolHost
+	for _, h := range p.hosts {
+		if h.healthy {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// PullImage pulls the image on every healthy host at once, the progress
+// tells the host in Node.
+func (p *Pool) PullImage(ctx context.Context, image string) (<-chan blueclient.PullProgress, error) {
+	hosts := p.healthyHosts()
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no healthy docker host to pull %s on", image)
+	}
+	out := make(chan blueclient.PullProgress)
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		progress, err := h.client.PullImage(ctx, image)
+		if err != nil {
+			progress = failedPull(image, err)
+		}
+		wg.Add(1)
+		go func(name string, progress <-chan blueclient.PullProgress) {
+			defer wg.Done()
+			for pp := range progress {
+				pp.Node = name
+				select {
+				case out <- pp:
+				case <-ctx.Done():
+				}
+			}
+		}(h.name, progress)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out, nil
+}
+
+func failedPull(image string, err error) <-chan blueclient.PullProgress {
+	progress := make(chan blueclient.PullProgress, 1)
+	progress <- blueclient.PullProgress{Image: image, Error: err.Error(), Time: time.Now()}
+	close(progress)
+	return progress
+}
+
+func (p *Pool) ListImages(ctx context.Context) ([]blueclient.ImageInfo, error) {
+	images := []blueclient.ImageInfo{}
+	for _, h := range p.healthyHosts() {
+		hostImages, err := h.client.ListImages(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list images of %s: %v", h.name, err)
+		}
+		for _, image := range hostImages {
+			image.Node = h.name
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+// RemoveUnusedImages returns the images removed from at least one host.
+func (p *Pool) RemoveUnusedImages(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	removed := []string{}
+	seen := make(map[string]bool)
+	for _, h := range p.healthyHosts() {
+		hostRemoved, err := h.client.RemoveUnusedImages(ctx, olderThan)
+		for _, image := range hostRemoved {
+			if !seen[image] {
+				seen[image] = true
+				removed = append(removed, image)
+			}
+		}
+		if err != nil {
+			return removed, fmt.Errorf("remove images of %s: %v", h.name, err)
+		}
+	}
+	return removed, nil
+}
+
+func (p *Pool) SetDebug(debug bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.debug = debug
+	for _, h := range p.hosts {
+		h.client.SetDebug(debug)
+	}
+	if p.debug {
+		log.Printf("[%d] [DOCKER_POOL_DEBUG] [DEBUG: %t]", 0, p.debug)
+	}
+}
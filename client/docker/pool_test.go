@@ -0,0 +1,202 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+func testPool(t *testing.T, capacities map[string]int, names ...string) *Pool {
+	p := &Pool{owners: make(map[string]*poolHost), healthInterval: defaultHealthInterval}
+	for _, name := range names {
+		AssertThat(t, p.Add(name, testClient(t), capacities[name]), Is{nil})
+	}
+	return p
+}
+
+func TestPoolPlacesOnLeastLoadedHost(t *testing.T) {
+	p := testPool(t, map[string]int{"small": 2, "large": 4}, "small", "large")
+
+	var placed []string
+	for i := 0; i < 6; i++ {
+		h, err := p.reserve()
+		AssertThat(t, err, Is{nil})
+		placed = append(placed, h.name)
+	}
+	AssertThat(t, placed, EqualTo{[]string{"small", "large", "large", "small", "large", "large"}})
+
+	_, err := p.reserve()
+	AssertThat(t, err, Is{ErrNoCapacity})
+}
+
+func TestPoolSkipsUnhealthyHost(t *testing.T) {
+	p := testPool(t, map[string]int{}, "up")
+	down, err := newClient(Options{Host: "tcp://127.0.0.1:1", Timeout: time.Second})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, p.Add("down", down, 0), Is{nil})
+
+	p.CheckHealth(context.Background())
+	hosts := p.Hosts()
+	AssertThat(t, hosts[0].Healthy, Is{true})
+	AssertThat(t, hosts[1].Healthy, Is{false})
+	AssertThat(t, hosts[1].Error, Not{EqualTo{""}})
+
+	for i := 0; i < 3; i++ {
+		h, err := p.reserve()
+		AssertThat(t, err, Is{nil})
+		AssertThat(t, h.name, EqualTo{"up"})
+	}
+}
+
+func TestPoolHealthCheckTimesOut(t *testing.T) {
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hung.Close()
+	p := testPool(t, map[string]int{}, "up")
+	p.healthTimeout = 100 * time.Millisecond
+	d, err := newClient(Options{Host: "tcp://" + hung.Listener.Addr().String()})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, p.Add("hung", d, 0), Is{nil})
+
+	done := make(chan struct{})
+	go func() {
+		p.CheckHealth(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("health check waits for the hung host")
+	}
+	AssertThat(t, p.Hosts()[1].Healthy, Is{false})
+}
+
+func TestPoolRoutesToOwner(t *testing.T) {
+	p := testPool(t, map[string]int{}, "docker-1")
+	var _ blueclient.Client = p
+	var _ blueclient.SessionManager = p
+	var _ blueclient.Isolator = p
+
+	_, err := p.GetLogs(context.Background(), "session-container")
+	AssertThat(t, err, Not{nil})
+
+	sessions, err := p.ListSessions(context.Background())
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, len(sessions), EqualTo{1})
+	host, ok := p.Host("session-container")
+	AssertThat(t, ok, Is{true})
+	AssertThat(t, host, EqualTo{"docker-1"})
+	AssertThat(t, p.Hosts()[0].Used, EqualTo{0})
+
+	AssertThat(t, p.RemoveSession(context.Background(), "session-container"), Is{nil})
+	_, ok = p.Host("session-container")
+	AssertThat(t, ok, Is{false})
+}
+
+func TestPoolWatchesHealthyHosts(t *testing.T) {
+	p := testPool(t, map[string]int{}, "up")
+	down, err := newClient(Options{Host: "tcp://127.0.0.1:1", Timeout: time.Second})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, p.Add("down", down, 0), Is{nil})
+	p.CheckHealth(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := p.Watch(ctx, map[string]string{"app": "blueio"})
+	AssertThat(t, err, Is{nil})
+	var received []blueclient.Event
+	for event := range events {
+		received = append(received, event)
+	}
+	AssertThat(t, len(received), EqualTo{2})
+
+	p.hosts[0].healthy = false
+	_, err = p.Watch(ctx, nil)
+	AssertThat(t, err, Not{nil})
+}
+
+func TestPoolListReportsFailedHosts(t *testing.T) {
+	p := testPool(t, map[string]int{}, "up")
+	down, err := newClient(Options{Host: "tcp://127.0.0.1:1", Timeout: time.Second})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, p.Add("down", down, 0), Is{nil})
+
+	sessions, err := p.ListSessions(context.Background())
+	AssertThat(t, len(sessions) > 0, Is{true})
+	hostsErr, ok := err.(*HostsError)
+	AssertThat(t, ok, Is{true})
+	AssertThat(t, len(hostsErr.Errors), EqualTo{1})
+	AssertThat(t, hostsErr.Errors["down"], Not{nil})
+}
+
+func TestPoolListKeepsConcurrentChanges(t *testing.T) {
+	p := testPool(t, map[string]int{}, "docker-1")
+	h := p.hosts[0]
+	for _, id := range []string{"stale", "gone"} {
+		h.sessions[id] = true
+		p.owners[id] = h
+	}
+
+	p.startListing(h)
+	reserved, err := p.reserve()
+	AssertThat(t, err, Is{nil})
+	p.release(reserved, "new")
+	p.forget("gone")
+	p.finishListing(h, []blueclient.Session{{ID: "gone"}, {ID: "listed"}, {ID: "done", Finished: true}}, true)
+
+	for _, id := range []string{"new", "listed", "done"} {
+		_, ok := p.Host(id)
+		AssertThat(t, ok, Is{true})
+	}
+	for _, id := range []string{"stale", "gone"} {
+		_, ok := p.Host(id)
+		AssertThat(t, ok, Is{false})
+	}
+	AssertThat(t, h.sessions, EqualTo{map[string]bool{"new": true, "listed": true}})
+	AssertThat(t, h.changed == nil, Is{true})
+}
+
+func TestPoolRunRefreshesSessions(t *testing.T) {
+	p := testPool(t, map[string]int{}, "docker-1")
+	p.healthInterval = 10 * time.Millisecond
+	h := p.hosts[0]
+	p.mu.Lock()
+	h.sessions["stale"] = true
+	p.owners["stale"] = h
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := p.Host("session-container"); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	_, ok := p.Host("session-container")
+	AssertThat(t, ok, Is{true})
+	_, ok = p.Host("stale")
+	AssertThat(t, ok, Is{false})
+	AssertThat(t, p.Hosts()[0].Used, EqualTo{0})
+}
+
+func TestPoolPullWithoutHealthyHosts(t *testing.T) {
+	p := testPool(t, map[string]int{}, "docker-1")
+	p.hosts[0].healthy = false
+	_, err := p.PullImage(context.Background(), "blueio/images:chrome_70.0")
+	AssertThat(t, err, Not{nil})
+}
@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+// ListSessions returns every container created by BlueIO, stopped ones
+// included.
+func (d *DockerClient) ListSessions(ctx context.Context) ([]blueclient.Session, error) {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_LIST_SESSIONS]", 0)
+	}
+	args := filters.NewArgs()
+	for k, v := range blueclient.ManagedSelector() {
+		args.Add("label", k+"="+v)
+	}
+	containers, err := d.Client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]blueclient.Session, 0, len(containers))
+	for _, c := range containers {
+		sessions = append(sessions, containerSession(c))
+	}
+	return sessions, nil
+}
+
+func containerSession(c types.Container) blueclient.Session {
+	s := blueclient.SessionFromLabels(c.ID, c.Labels, c.Labels)
+	s.State = c.State
+	s.Finished = c.State == "exited" || c.State == "dead"
+	s.Created = time.Unix(c.Created, 0)
+	return s
+}
+
+// RemoveSession force removes the container along with its anonymous volumes
+// and its isolated network.
+func (d *DockerClient) RemoveSession(ctx context.Context, id string) error {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_REMOVE_SESSION] [ID: %s]", 0, id)
+	}
+	// the network is named after the full ID, which is unknown once the
+	// container is gone
+	id, err := d.containerID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = d.Client.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true, RemoveVolumes: true})
+	if err != nil {
+		return err
+	}
+	return d.RemoveIsolation(ctx, id)
+}
@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/docker/docker/api/types"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+func (d *DockerClient) Stats(ctx context.Context, id string, stream bool) (<-chan blueclient.Stats, error) {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_STATS] [ID: %s] [STREAM: %t]", 0, id, stream)
+	}
+	resp, err := d.Client.ContainerStats(ctx, id, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan blueclient.Stats)
+	go func() {
+		defer close(out)
+		defer resp.Body.Close()
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var s types.StatsJSON
+			if err := decoder.Decode(&s); err != nil {
+				if d.debug {
+					log.Printf("[%d] [DOCKER_STATS_STOPPED] [ID: %s] [ERROR: %v]", 0, id, err)
+				}
+				return
+			}
+			select {
+			case out <- toStats(id, &s):
+			case <-ctx.Done():
+				return
+			}
+			if !stream {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func toStats(id string, s *types.StatsJSON) blueclient.Stats {
+	stats := blueclient.Stats{
+		ID:          id,
+		Time:        s.Read,
+		CPUPercent:  cpuPercent(s),
+		MemoryUsage: s.MemoryStats.Usage,
+		MemoryLimit: s.MemoryStats.Limit,
+	}
+	for _, network := range s.Networks {
+		stats.NetworkRx += network.RxBytes
+		stats.NetworkTx += network.TxBytes
+	}
+	return stats
+}
+
+// cpuPercent uses the same formula as `docker stats`
+func cpuPercent(s *types.StatsJSON) float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
@@ -0,0 +1,108 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+const healthy = "healthy"
+
+// WaitReady waits until the container is running and, when it defines a
+// healthcheck, healthy.
+func (d *DockerClient) WaitReady(ctx context.Context, id string) (*blueclient.Endpoint, error) {
+	if d.debug {
+		log.Printf("[%d] [DOCKER_WAIT_READY] [ID: %s]", 0, id)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	args := filters.NewArgs()
+	args.Add("type", events.ContainerEventType)
+	args.Add("container", id)
+	messages, errs := d.Client.Events(ctx, types.EventsOptions{Filters: args})
+
+	for {
+		container, err := d.Client.ContainerInspect(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		ep, err := containerReadiness(&container)
+		if ep != nil || err != nil {
+			return ep, err
+		}
+
+		if err := waitForChange(ctx, id, messages, errs); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// waitForChange blocks until an event that can change the readiness of the
+// container arrives.
+func waitForChange(ctx context.Context, id string, messages <-chan events.Message, errs <-chan error) error {
+	for {
+		select {
+		case msg := <-messages:
+			switch msg.Action {
+			case "start", "health_status: " + healthy, "die", "oom":
+				return nil
+			case "destroy":
+				return &blueclient.NotReadyError{ID: id, Reason: "removed"}
+			}
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("watching events of %s: %v", id, err)
+		}
+	}
+}
+
+func containerReadiness(container *types.ContainerJSON) (*blueclient.Endpoint, error) {
+	state := container.State
+	if state == nil {
+		return nil, nil
+	}
+	switch {
+	case state.OOMKilled:
+		return nil, &blueclient.NotReadyError{ID: container.ID, Reason: "OOMKilled", Message: fmt.Sprintf("exit code %d", state.ExitCode)}
+	case state.Dead || (!state.Running && state.Status == "exited"):
+		return nil, &blueclient.NotReadyError{ID: container.ID, Reason: "exited", Message: exitMessage(state)}
+	case !state.Running:
+		return nil, nil
+	case state.Health != nil && state.Health.Status != healthy:
+		return nil, nil
+	}
+	return containerEndpoint(container), nil
+}
+
+func exitMessage(state *types.ContainerState) string {
+	if state.Error != "" {
+		return fmt.Sprintf("exit code %d: %s", state.ExitCode, state.Error)
+	}
+	return fmt.Sprintf("exit code %d", state.ExitCode)
+}
+
+func containerEndpoint(container *types.ContainerJSON) *blueclient.Endpoint {
+	ep := &blueclient.Endpoint{Ports: make(map[int]int)}
+	if settings := container.NetworkSettings; settings != nil {
+		ep.IP = settings.IPAddress
+		for _, network := range settings.Networks {
+			if ep.IP == "" {
+				ep.IP = network.IPAddress
+			}
+		}
+	}
+	if container.Config != nil {
+		for port := range container.Config.ExposedPorts {
+			ep.Ports[port.Int()] = port.Int()
+		}
+	}
+	return ep
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Endpoint is where a ready session container or pod can be reached.
+type Endpoint struct {
+	IP string `json:"ip"`
+	// Ports maps the ports exposed by the container to the ports they are
+	// reachable on at IP.
+	Ports map[int]int `json:"ports,omitempty"`
+}
+
+// Address returns host:port for a container port, falling back to the port
+// itself when it has no explicit mapping.
+func (e *Endpoint) Address(port int) string {
+	if mapped, ok := e.Ports[port]; ok {
+		port = mapped
+	}
+	return net.JoinHostPort(e.IP, strconv.Itoa(port))
+}
+
+// NotReadyError is returned by WaitReady when the container or pod can not
+// become ready anymore, e.g. because its image can not be pulled or it exited.
+type NotReadyError struct {
+	ID      string
+	Reason  string
+	Message string
+}
+
+func (e *NotReadyError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s will not become ready: %s", e.ID, e.Reason)
+	}
+	return fmt.Sprintf("%s will not become ready: %s: %s", e.ID, e.Reason, e.Message)
+}
@@ -0,0 +1,18 @@
+package client
+
+import (
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+)
+
+func TestEndpointAddress(t *testing.T) {
+	ep := &Endpoint{IP: "10.0.0.1", Ports: map[int]int{4444: 32768}}
+	AssertThat(t, ep.Address(4444), EqualTo{"10.0.0.1:32768"})
+	AssertThat(t, ep.Address(5900), EqualTo{"10.0.0.1:5900"})
+}
+
+func TestNotReadyError(t *testing.T) {
+	err := &NotReadyError{ID: "blueio-abc", Reason: "ImagePullBackOff", Message: "image not found"}
+	AssertThat(t, err.Error(), EqualTo{"blueio-abc will not become ready: ImagePullBackOff: image not found"})
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/kolobok01/util/sse"
+)
+
+type EventType string
+
+const (
+	EventCreated   EventType = "created"
+	EventStarted   EventType = "started"
+	EventReady     EventType = "ready"
+	EventExited    EventType = "exited"
+	EventOOMKilled EventType = "oomkilled"
+	EventDeleted   EventType = "deleted"
+)
+
+// Event is a backend independent lifecycle change of a session container or pod.
+type Event struct {
+	Type     EventType         `json:"type"`
+	ID       string            `json:"id"`
+	Name     string            `json:"name,omitempty"`
+	ExitCode int               `json:"exitCode,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Time     time.Time         `json:"time"`
+}
+
+// PublishEvents forwards every event to the broker as JSON until the
+// channel is closed.
+func PublishEvents(broker sse.Broker, events <-chan Event) {
+	for event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("ERROR: cannot marshal event %+v: %v", event, err)
+			continue
+		}
+		broker.Notify(data)
+	}
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+)
+
+type mockBroker struct {
+	messages chan string
+}
+
+func (mb *mockBroker) HasClients() bool {
+	return true
+}
+
+func (mb *mockBroker) Notify(data []byte) {
+	mb.messages <- string(data)
+}
+
+func (mb *mockBroker) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.WriteHeader(http.StatusOK)
+}
+
+func TestPublishEvents(t *testing.T) {
+	broker := &mockBroker{messages: make(chan string, 10)}
+	events := make(chan Event, 1)
+	events <- Event{Type: EventExited, ID: "some-id", ExitCode: 1, Time: time.Unix(0, 0).UTC()}
+	close(events)
+	PublishEvents(broker, events)
+	AssertThat(t, <-broker.messages, EqualTo{`{"type":"exited","id":"some-id","exitCode":1,"time":"1970-01-01T00:00:00Z"}`})
+}
@@ -0,0 +1,518 @@
+// Package fake provides a thread-safe in-memory client.Client for tests of
+// code built on top of the session backends. Test code drives the lifecycle
+// of the simulated containers, feeds their logs and injects failures.
+package fake
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kolobok01/util"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+const FakeType = "FAKE"
+
+type State string
+
+const (
+	StateCreated State = "created"
+	StateRunning State = "running"
+	StateExited  State = "exited"
+)
+
+type Op string
+
+const (
+	OpGetLogs  Op = "GetLogs"
+	OpCopyTo   Op = "CopyTo"
+	OpCopyFrom Op = "CopyFrom"
+	OpWatch    Op = "Watch"
+	OpStats    Op = "Stats"
+	OpExec     Op = "Exec"
+	OpWait     Op = "WaitReady"
+	OpList     Op = "ListSessions"
+	OpRemove   Op = "RemoveSession"
+)
+
+// Container is a snapshot of a simulated container.
+type Container struct {
+	ID       string
+	Name     string
+	Labels   map[string]string
+	State    State
+	Ready    bool
+	ExitCode int
+	IP       string
+	Created  time.Time
+}
+
+type container struct {
+	Container
+	index uint64
+	logs  bytes.Buffer
+	files map[string][]byte
+	stats *blueclient.Stats
+	execs map[string]*blueclient.ExecResult
+}
+
+type watcher struct {
+	selector map[string]string
+	queue    []blueclient.Event
+	notify   chan struct{}
+}
+
+type Client struct {
+	Type string
+
+	mu         sync.Mutex
+	ids        util.Counter
+	containers map[string]*container
+	watchers   map[*watcher]bool
+	errors     map[Op]error
+	latencies  map[Op]time.Duration
+}
+
+func NewClient() *Client {
+	return &Client{
+		Type:       FakeType,
+		ids:        util.NewCounter(),
+		containers: make(map[string]*container),
+		watchers:   make(map[*watcher]bool),
+		errors:     make(map[Op]error),
+		latencies:  make(map[Op]time.Duration),
+	}
+}
+
+// Fail makes every following call of op return err. A nil err removes the failure.
+func (c *Client) Fail(op Op, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		delete(c.errors, op)
+		return
+	}
+	c.errors[op] = err
+}
+
+// Delay makes every following call of op wait for d or for its context.
+func (c *Client) Delay(op Op, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latencies[op] = d
+}
+
+func (c *Client) Create(name string, labels map[string]string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index := c.ids.Count()
+	id := fmt.Sprintf("fake-%d", index)
+	copied := make(map[string]string)
+	for k, v := range labels {
+		copied[k] = v
+	}
+	ct := &container{
+		Container: Container{
+			ID:      id,
+			Name:    name,
+			Labels:  copied,
+			State:   StateCreated,
+			Created: time.Now(),
+		},
+		index: index,
+		files: make(map[string][]byte),
+		execs: make(map[string]*blueclient.ExecResult),
+	}
+	c.containers[id] = ct
+	c.emit(ct, blueclient.EventCreated, 0)
+	return id
+}
+
+func (c *Client) Start(id string) error {
+	return c.update(id, func(ct *container) error {
+		if ct.State != StateCreated {
+			return fmt.Errorf("container %s is %s", id, ct.State)
+		}
+		ct.State = StateRunning
+		ct.IP = fmt.Sprintf("10.0.%d.%d", (ct.index+1)/256, (ct.index+1)%256)
+		c.emit(ct, blueclient.EventStarted, 0)
+		return nil
+	})
+}
+
+func (c *Client) SetReady(id string) error {
+	return c.update(id, func(ct *container) error {
+		if ct.State != StateRunning {
+			return fmt.Errorf("container %s is %s", id, ct.State)
+		}
+		ct.Ready = true
+		c.emit(ct, blueclient.EventReady, 0)
+		return nil
+	})
+}
+
+func (c *Client) Exit(id string, code int) error {
+	return c.update(id, func(ct *container) error {
+		return c.exit(ct, code)
+	})
+}
+
+// OOMKill exits the container with code 137 after an OOMKilled event.
+func (c *Client) OOMKill(id string) error {
+	return c.update(id, func(ct *container) error {
+		c.emit(ct, blueclient.EventOOMKilled, 0)
+		return c.exit(ct, 137)
+	})
+}
+
+func (c *Client) Delete(id string) error {
+	return c.update(id, func(ct *container) error {
+		delete(c.containers, id)
+		c.emit(ct, blueclient.EventDeleted, ct.ExitCode)
+		return nil
+	})
+}
+
+func (c *Client) AppendLogs(id string, data []byte) error {
+	return c.update(id, func(ct *container) error {
+		ct.logs.Write(data)
+		return nil
+	})
+}
+
+func (c *Client) SetStats(id string, stats blueclient.Stats) error {
+	return c.update(id, func(ct *container) error {
+		stats.ID = id
+		ct.stats = &stats
+		return nil
+	})
+}
+
+// SetExecResult defines the result of running cmd in the container. Commands
+// without a defined result succeed with empty output.
+func (c *Client) SetExecResult(id string, cmd []string, result blueclient.ExecResult) error {
+	return c.update(id, func(ct *container) error {
+		ct.execs[strings.Join(cmd, " ")] = &result
+		return nil
+	})
+}
+
+func (c *Client) Container(id string) (Container, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ct, ok := c.containers[id]
+	if !ok {
+		return Container{}, false
+	}
+	return ct.Container, true
+}
+
+func (c *Client) Containers() []Container {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var list []Container
+	for _, ct := range c.containers {
+		list = append(list, ct.Container)
+	}
+	return list
+}
+
+// File returns a file previously copied into the container.
+func (c *Client) File(id, filePath string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ct, ok := c.containers[id]
+	if !ok {
+		return nil, false
+	}
+	data, ok := ct.files[path.Clean(filePath)]
+	return data, ok
+}
+
+func (c *Client) GetType() string {
+	return c.Type
+}
+
+func (c *Client) GetLogs(ctx context.Context, id string) (io.ReadCloser, error) {
+	var logs []byte
+	err := c.call(ctx, OpGetLogs, id, func(ct *container) error {
+		logs = append(logs, ct.logs.Bytes()...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(logs)), nil
+}
+
+func (c *Client) CopyTo(ctx context.Context, id, dstPath string, content io.Reader) error {
+	files := make(map[string][]byte)
+	tr := tar.NewReader(content)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		files[path.Join(dstPath, hdr.Name)] = data
+	}
+	return c.call(ctx, OpCopyTo, id, func(ct *container) error {
+		for name, data := range files {
+			ct.files[name] = data
+		}
+		return nil
+	})
+}
+
+func (c *Client) CopyFrom(ctx context.Context, id, srcPath string) (io.ReadCloser, error) {
+	var data []byte
+	err := c.call(ctx, OpCopyFrom, id, func(ct *container) error {
+		content, ok := ct.files[path.Clean(srcPath)]
+		if !ok {
+			return fmt.Errorf("no such file in container %s: %s", id, srcPath)
+		}
+		data = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r, err := blueclient.BytesToTar(path.Base(srcPath), data)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(r), nil
+}
+
+func (c *Client) Watch(ctx context.Context, selector map[string]string) (<-chan blueclient.Event, error) {
+	if err := c.before(ctx, OpWatch); err != nil {
+		return nil, err
+	}
+	return c.watch(ctx, selector), nil
+}
+
+func (c *Client) watch(ctx context.Context, selector map[string]string) <-chan blueclient.Event {
+	w := &watcher{selector: selector, notify: make(chan struct{}, 1)}
+	c.mu.Lock()
+	c.watchers[w] = true
+	c.mu.Unlock()
+
+	out := make(chan blueclient.Event)
+	go func() {
+		defer close(out)
+		defer func() {
+			c.mu.Lock()
+			delete(c.watchers, w)
+			c.mu.Unlock()
+		}()
+		for {
+			c.mu.Lock()
+			queue := w.queue
+			w.queue = nil
+			c.mu.Unlock()
+			for _, event := range queue {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-w.notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Stats sends the sample defined with SetStats. A streaming channel stays
+// open until the context is done.
+func (c *Client) Stats(ctx context.Context, id string, stream bool) (<-chan blueclient.Stats, error) {
+	var stats blueclient.Stats
+	err := c.call(ctx, OpStats, id, func(ct *container) error {
+		if ct.State != StateRunning {
+			return fmt.Errorf("container %s is not running", id)
+		}
+		stats = blueclient.Stats{ID: id, Time: time.Now()}
+		if ct.stats != nil {
+			stats = *ct.stats
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan blueclient.Stats, 1)
+	out <- stats
+	if !stream {
+		close(out)
+		return out, nil
+	}
+	go func() {
+		<-ctx.Done()
+		close(out)
+	}()
+	return out, nil
+}
+
+func (c *Client) Exec(ctx context.Context, id string, cmd []string) (*blueclient.ExecResult, error) {
+	result := &blueclient.ExecResult{}
+	err := c.call(ctx, OpExec, id, func(ct *container) error {
+		if ct.State != StateRunning {
+			return fmt.Errorf("container %s is not running", id)
+		}
+		if r, ok := ct.execs[strings.Join(cmd, " ")]; ok {
+			*result = *r
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// WaitReady waits until the container is marked ready with SetReady and
+// fails when it exits or is deleted before.
+func (c *Client) WaitReady(ctx context.Context, id string) (*blueclient.Endpoint, error) {
+	if err := c.before(ctx, OpWait); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := c.watch(ctx, nil)
+	for {
+		ct, ok := c.Container(id)
+		switch {
+		case !ok:
+			return nil, &blueclient.NotReadyError{ID: id, Reason: "deleted"}
+		case ct.State == StateExited:
+			return nil, &blueclient.NotReadyError{ID: id, Reason: "exited", Message: fmt.Sprintf("exit code %d", ct.ExitCode)}
+		case ct.Ready:
+			return &blueclient.Endpoint{IP: ct.IP}, nil
+		}
+		if _, ok := <-events; !ok {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// ListSessions returns the containers labelled as managed by BlueIO.
+func (c *Client) ListSessions(ctx context.Context) ([]blueclient.Session, error) {
+	if err := c.before(ctx, OpList); err != nil {
+		return nil, err
+	}
+	var sessions []blueclient.Session
+	for _, ct := range c.Containers() {
+		if !matches(blueclient.ManagedSelector(), ct.Labels) {
+			continue
+		}
+		s := blueclient.SessionFromLabels(ct.ID, ct.Labels, ct.Labels)
+		s.State = string(ct.State)
+		s.Finished = ct.State == StateExited
+		s.Created = ct.Created
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+func (c *Client) RemoveSession(ctx context.Context, id string) error {
+	if err := c.before(ctx, OpRemove); err != nil {
+		return err
+	}
+	return c.Delete(id)
+}
+
+// call applies the injected latency and error of op and then runs fn on the
+// container under the lock.
+func (c *Client) call(ctx context.Context, op Op, id string, fn func(*container) error) error {
+	if err := c.before(ctx, op); err != nil {
+		return err
+	}
+	return c.update(id, fn)
+}
+
+func (c *Client) before(ctx context.Context, op Op) error {
+	c.mu.Lock()
+	latency, err := c.latencies[op], c.errors[op]
+	c.mu.Unlock()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+func (c *Client) update(id string, fn func(*container) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ct, ok := c.containers[id]
+	if !ok {
+		return fmt.Errorf("no such container: %s", id)
+	}
+	return fn(ct)
+}
+
+func (c *Client) exit(ct *container, code int) error {
+	if ct.State != StateRunning {
+		return fmt.Errorf("container %s is %s", ct.ID, ct.State)
+	}
+	ct.State = StateExited
+	ct.Ready = false
+	ct.ExitCode = code
+	c.emit(ct, blueclient.EventExited, code)
+	return nil
+}
+
+// emit queues the event for every matching watcher, c.mu must be held.
+func (c *Client) emit(ct *container, eventType blueclient.EventType, exitCode int) {
+	event := blueclient.Event{
+		Type:     eventType,
+		ID:       ct.ID,
+		Name:     ct.Name,
+		ExitCode: exitCode,
+		Labels:   ct.Labels,
+		Time:     time.Now(),
+	}
+	for w := range c.watchers {
+		if !matches(w.selector, ct.Labels) {
+			continue
+		}
+		w.queue = append(w.queue, event)
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func matches(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+	blueclient "github.com/kolobok01/util/client"
+)
+
+var _ blueclient.Client = NewClient()
+
+func TestLifecycleEvents(t *testing.T) {
+	c := NewClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := c.Watch(ctx, map[string]string{"app": "blueio"})
+	AssertThat(t, err, Is{nil})
+
+	c.Create("ignored", map[string]string{"app": "other"})
+	id := c.Create("session", map[string]string{"app": "blueio"})
+	AssertThat(t, c.Start(id), Is{nil})
+	AssertThat(t, c.SetReady(id), Is{nil})
+	AssertThat(t, c.OOMKill(id), Is{nil})
+	AssertThat(t, c.Delete(id), Is{nil})
+
+	var types []blueclient.EventType
+	for i := 0; i < 6; i++ {
+		event := <-events
+		AssertThat(t, event.ID, EqualTo{id})
+		types = append(types, event.Type)
+	}
+	AssertThat(t, types, EqualTo{[]blueclient.EventType{
+		blueclient.EventCreated,
+		blueclient.EventStarted,
+		blueclient.EventReady,
+		blueclient.EventOOMKilled,
+		blueclient.EventExited,
+		blueclient.EventDeleted,
+	}})
+	_, ok := c.Container(id)
+	AssertThat(t, ok, Is{false})
+
+	cancel()
+	_, ok = <-events
+	AssertThat(t, ok, Is{false})
+}
+
+func TestInvalidTransition(t *testing.T) {
+	c := NewClient()
+	id := c.Create("session", nil)
+	AssertThat(t, c.Exit(id, 0), Not{nil})
+	AssertThat(t, c.Start("missing"), Not{nil})
+}
+
+func TestLogs(t *testing.T) {
+	c := NewClient()
+	id := c.Create("session", nil)
+	AssertThat(t, c.AppendLogs(id, []byte("line 1\n")), Is{nil})
+	AssertThat(t, c.AppendLogs(id, []byte("line 2\n")), Is{nil})
+	r, err := c.GetLogs(context.Background(), id)
+	AssertThat(t, err, Is{nil})
+	data, _ := ioutil.ReadAll(r)
+	AssertThat(t, string(data), EqualTo{"line 1\nline 2\n"})
+}
+
+func TestCopy(t *testing.T) {
+	c := NewClient()
+	id := c.Create("session", nil)
+	content, _ := blueclient.BytesToTar("file.txt", []byte("data"))
+	AssertThat(t, c.CopyTo(context.Background(), id, "/tmp", content), Is{nil})
+	data, ok := c.File(id, "/tmp/file.txt")
+	AssertThat(t, ok, Is{true})
+	AssertThat(t, string(data), EqualTo{"data"})
+
+	r, err := c.CopyFrom(context.Background(), id, "/tmp/file.txt")
+	AssertThat(t, err, Is{nil})
+	name, data, err := blueclient.TarToBytes(r)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, name, EqualTo{"file.txt"})
+	AssertThat(t, string(data), EqualTo{"data"})
+
+	_, err = c.CopyFrom(context.Background(), id, "/tmp/missing.txt")
+	AssertThat(t, err, Not{nil})
+}
+
+func TestExec(t *testing.T) {
+	c := NewClient()
+	id := c.Create("session", nil)
+	_, err := c.Exec(context.Background(), id, []string{"ls"})
+	AssertThat(t, err, Not{nil})
+
+	c.Start(id)
+	c.SetExecResult(id, []string{"cat", "/etc/hostname"}, blueclient.ExecResult{ExitCode: 1, Stderr: []byte("denied")})
+	result, err := c.Exec(context.Background(), id, []string{"cat", "/etc/hostname"})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, *result, EqualTo{blueclient.ExecResult{ExitCode: 1, Stderr: []byte("denied")}})
+
+	result, err = c.Exec(context.Background(), id, []string{"true"})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, result.ExitCode, EqualTo{0})
+}
+
+func TestStats(t *testing.T) {
+	c := NewClient()
+	id := c.Create("session", nil)
+	c.Start(id)
+	c.SetStats(id, blueclient.Stats{CPUPercent: 12.5})
+	usage, err := blueclient.AggregateStats(context.Background(), c, []string{id})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, usage.CPUPercent, EqualTo{12.5})
+}
+
+func TestInjectedError(t *testing.T) {
+	c := NewClient()
+	id := c.Create("session", nil)
+	c.Fail(OpGetLogs, errors.New("injected"))
+	_, err := c.GetLogs(context.Background(), id)
+	AssertThat(t, err.Error(), EqualTo{"injected"})
+
+	c.Fail(OpGetLogs, nil)
+	_, err = c.GetLogs(context.Background(), id)
+	AssertThat(t, err, Is{nil})
+}
+
+func TestInjectedLatency(t *testing.T) {
+	c := NewClient()
+	id := c.Create("session", nil)
+	c.Delay(OpGetLogs, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.GetLogs(ctx, id)
+	AssertThat(t, err, EqualTo{context.DeadlineExceeded})
+}
+
+func TestWaitReady(t *testing.T) {
+	c := NewClient()
+	id := c.Create("session", nil)
+	go func() {
+		c.Start(id)
+		c.SetReady(id)
+	}()
+	ep, err := c.WaitReady(context.Background(), id)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, ep.IP, Not{""})
+}
+
+func TestWaitReadyExited(t *testing.T) {
+	c := NewClient()
+	id := c.Create("session", nil)
+	go func() {
+		c.Start(id)
+		c.Exit(id, 1)
+	}()
+	_, err := c.WaitReady(context.Background(), id)
+	_, ok := err.(*blueclient.NotReadyError)
+	AssertThat(t, ok, Is{true})
+}
+
+func TestReapFinished(t *testing.T) {
+	c := NewClient()
+	id := c.Create("session", blueclient.ManagedSelector())
+	c.Create("other", nil)
+	c.Start(id)
+	c.Exit(id, 0)
+	report, err := blueclient.NewReaper(c, blueclient.ReaperOptions{}).Reap(context.Background())
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, report.Checked, EqualTo{1})
+	_, ok := c.Container(id)
+	AssertThat(t, ok, Is{false})
+	AssertThat(t, len(c.Containers()), EqualTo{1})
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseImage extracts the browser and its version from a session image
+// reference in <image>:<browser>_<version> format, e.g.
+// blueio/images:chrome_70.0 or registry:5000/blueio/images:chrome_70.0. The
+// version is returned as written in the tag.
+func ParseImage(image string) (string, string, error) {
+	colon := strings.LastIndex(image, ":")
+	if colon < 0 || colon < strings.LastIndex(image, "/") {
+		return "", "", fmt.Errorf("incorrect input, should be in <image>:<version> format, got: %s", image)
+	}
+	tag := image[colon+1:]
+	underscore := strings.Index(tag, "_")
+	if underscore <= 0 || underscore == len(tag)-1 {
+		return "", "", fmt.Errorf("incorrect input, should be in <browser>_<version_number> format, got: %s", tag)
+	}
+	browser, version := tag[:underscore], tag[underscore+1:]
+	if err := validVersion(version); err != nil {
+		return "", "", err
+	}
+	return browser, version, nil
+}
+
+// SessionBrowser returns browser and version when both are known, e.g.
+// resolved from the catalog, whose images need no <browser>_<version> tag,
+// and parses them from the image otherwise.
+func SessionBrowser(image, browser, version string) (string, string, error) {
+	if browser != "" && version != "" {
+		return browser, version, nil
+	}
+	return ParseImage(image)
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+)
+
+func TestParseImage(t *testing.T) {
+	browser, version, err := ParseImage("blueio/images:firefox_63.1")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, browser, EqualTo{"firefox"})
+	AssertThat(t, version, EqualTo{"63.1"})
+}
+
+func TestParseImageKeepsVersion(t *testing.T) {
+	_, version, err := ParseImage("registry:5000/blueio/images:safari_10.10")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, version, EqualTo{"10.10"})
+
+	browser, version, err := ParseImage("blueio/images:chrome_120.0.6099.109")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, browser, EqualTo{"chrome"})
+	AssertThat(t, version, EqualTo{"120.0.6099.109"})
+}
+
+func TestParseImageErrors(t *testing.T) {
+	for _, image := range []string{
+		"chrome",
+		"blueio/images:chrome",
+		"blueio/images:chrome_latest",
+		"blueio/images:chrome_",
+		"blueio/images:_70.0",
+		"blueio/images:chrome_70..0",
+		"registry:5000/blueio/images",
+	} {
+		_, _, err := ParseImage(image)
+		AssertThat(t, err, Not{nil})
+	}
+}
+
+func TestSessionBrowser(t *testing.T) {
+	browser, version, err := SessionBrowser("selenoid/chrome:120.0", "chrome", "120.0")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, browser, EqualTo{"chrome"})
+	AssertThat(t, version, EqualTo{"120.0"})
+
+	_, _, err = SessionBrowser("selenoid/chrome:120.0", "", "")
+	AssertThat(t, err, Not{nil})
+}
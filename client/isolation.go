@@ -0,0 +1,32 @@
+package client
+
+import "context"
+
+// IsolationPolicy restricts the network of a session to its hub and an
+// allowlist.
+type IsolationPolicy struct {
+	// HubCIDRs may reach the session, on Kubernetes.
+	HubCIDRs []string
+	// HubSelector selects the hub pods that may reach the session, on
+	// Kubernetes.
+	HubSelector map[string]string
+	// HubContainers are connected to the session network, on Docker.
+	HubContainers []string
+	// EgressCIDRs are the destinations the session may reach, on
+	// Kubernetes. Docker can not filter by destination and rejects policies
+	// with egress CIDRs, its sessions can only reach the hub.
+	EgressCIDRs []string
+	// AllowDNS lets the session resolve names through the cluster DNS.
+	AllowDNS bool
+	// Ports restricts the ingress from the hub, all ports by default.
+	Ports []int
+}
+
+// Isolator is implemented by clients able to isolate the network of a
+// session. The isolation is also removed with the session.
+type Isolator interface {
+	// Isolate returns the endpoint the session is reachable on once
+	// isolated, which replaces the one it was started with on Docker.
+	Isolate(ctx context.Context, id string, policy IsolationPolicy) (*Endpoint, error)
+	RemoveIsolation(ctx context.Context, id string) error
+}
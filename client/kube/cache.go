@@ -0,0 +1,231 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// relistInterval separates the attempts to list the pods again after a
+// failed list or watch.
+const relistInterval = time.Second
+
+// podStore holds the session pods of the namespace by name.
+type podStore struct {
+	mu     sync.RWMutex
+	pods   map[string]*apiv1.Pod
+	synced chan struct{}
+}
+
+func newPodStore() *podStore {
+	return &podStore{pods: make(map[string]*apiv1.Pod), synced: make(chan struct{})}
+}
+
+func (s *podStore) hasSynced() bool {
+	select {
+	case <-s.synced:
+		return true
+	default:
+		return false
+	}
+}
+
+// replace sets the pods to a new listing, the first one syncs the store.
+func (s *podStore) replace(pods []apiv1.Pod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pods = make(map[string]*apiv1.Pod, len(pods))
+	for i := range pods {
+		s.pods[pods[i].Name] = &pods[i]
+	}
+	if !s.hasSynced() {
+		close(s.synced)
+	}
+}
+
+func (s *podStore) update(eventType watch.EventType, pod *apiv1.Pod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if eventType == watch.Deleted {
+		delete(s.pods, pod.Name)
+		return
+	}
+	s.pods[pod.Name] = pod
+}
+
+func (s *podStore) get(name string) *apiv1.Pod {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if pod, ok := s.pods[name]; ok {
+		return pod.DeepCopy()
+	}
+	return nil
+}
+
+// list returns copies of the pods carrying the label, all of them when key
+// is empty.
+func (s *podStore) list(key, value string) []apiv1.Pod {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pods := make([]apiv1.Pod, 0, len(s.pods))
+	for _, pod := range s.pods {
+		if key == "" || pod.Labels[key] == value {
+			pods = append(pods, *pod.DeepCopy())
+		}
+	}
+	return pods
+}
+
+// StartCache lists and watches the session pods of the namespace until the
+// context is done, listing them again every resync, zero never does. Once
+// listed, lookups and listings are served from the cache and fall back to
+// the API server on a cache miss.
+func (k *KubeClient) StartCache(ctx context.Context, resync time.Duration) {
+	if k.debug {
+		log.Printf("DEBUG: StartCache: resync: %s", resync)
+	}
+
+	store := newPodStore()
+	k.mu.Lock()
+	k.pods = store
+	k.mu.Unlock()
+
+	go func() {
+		k.syncPods(ctx, store, resync)
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		if k.pods == store {
+			k.pods = nil
+		}
+	}()
+}
+
+// syncPods keeps the store up to date until the context is done. Watches
+// expire, the pods are listed again when they do.
+func (k *KubeClient) syncPods(ctx context.Context, store *podStore, resync time.Duration) {
+	selector := labels.SelectorFromSet(blueclient.ManagedSelector()).String()
+	for ctx.Err() == nil {
+		err := k.watchPodStore(ctx, store, selector, resync)
+		if err == nil {
+			continue
+		}
+		log.Printf("WARNING: cannot sync session pods: %v", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(relistInterval):
+		}
+	}
+}
+
+// watchPodStore lists the pods into the store and applies the events of a
+// watch started from the listing, until the watch ends or resync elapses.
+func (k *KubeClient) watchPodStore(ctx context.Context, store *podStore, selector string, resync time.Duration) error {
+	list, err := k.PodManager.List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+	store.replace(list.Items)
+
+	w, err := k.PodManager.Watch(metav1.ListOptions{
+		LabelSelector:   selector,
+		ResourceVersion: list.ResourceVersion,
+	})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	var expired <-chan time.Time
+	if resync > 0 {
+		timer := time.NewTimer(resync)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		select {
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				if k.debug {
+					log.Printf("DEBUG: StartCache: watch expired, listing again")
+				}
+				return nil
+			}
+			if e.Type == watch.Error {
+				return fmt.Errorf("watching session pods: %v", e.Object)
+			}
+			if pod, ok := e.Object.(*apiv1.Pod); ok {
+				store.update(e.Type, pod)
+			}
+		case <-expired:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Synced reports whether the pod cache is started and has synced.
+func (k *KubeClient) Synced() bool {
+	return k.cachedPods() != nil
+}
+
+// WaitForCacheSync blocks until the pod cache has synced and returns false
+// when the context is done first or the cache is not started.
+func (k *KubeClient) WaitForCacheSync(ctx context.Context) bool {
+	k.mu.Lock()
+	store := k.pods
+	k.mu.Unlock()
+	if store == nil {
+		return false
+	}
+	select {
+	case <-store.synced:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// cachedPods returns nil unless the cache is synced.
+func (k *KubeClient) cachedPods() *podStore {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.pods == nil || !k.pods.hasSynced() {
+		return nil
+	}
+	return k.pods
+}
+
+func (k *KubeClient) cachedPod(name string) *apiv1.Pod {
+	pods := k.cachedPods()
+	if pods == nil {
+		return nil
+	}
+	return pods.get(name)
+}
+
+// cachedPodsByLabel returns false when the cache is not synced.
+func (k *KubeClient) cachedPodsByLabel(key, value string) ([]apiv1.Pod, bool) {
+	pods := k.cachedPods()
+	if pods == nil {
+		return nil, false
+	}
+	return pods.list(key, value), true
+}
+
+func (k *KubeClient) cachedPodList() ([]apiv1.Pod, bool) {
+	pods := k.cachedPods()
+	if pods == nil {
+		return nil, false
+	}
+	return pods.list("", ""), true
+}
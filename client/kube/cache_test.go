@@ -0,0 +1,112 @@
+package kube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func cachedClient(pods ...*apiv1.Pod) *KubeClient {
+	store := newPodStore()
+	for _, pod := range pods {
+		store.update(watch.Added, pod)
+	}
+	close(store.synced)
+	return &KubeClient{namespace: defaultNamespace, pods: store}
+}
+
+func cachedPod(name, requestID, sessionID string) *apiv1.Pod {
+	return &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: defaultNamespace,
+		Labels: map[string]string{
+			blueclient.ManagedLabel:       blueclient.ManagedValue,
+			blueclient.RequestIDLabel:     requestID,
+			defaultSeleniumSessionIDField: sessionID,
+		},
+	}}
+}
+
+func TestCachedLookups(t *testing.T) {
+	k := cachedClient(cachedPod("blueio-a", "1", "abc"), cachedPod("blueio-b", "2", "def"))
+	AssertThat(t, k.Synced(), Is{true})
+
+	pod, err := k.GetPodByName(context.Background(), "blueio-a")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, pod.Name, EqualTo{"blueio-a"})
+
+	pod, err = k.GetPodBySessionID(context.Background(), "def")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, pod.Name, EqualTo{"blueio-b"})
+
+	pod, err = k.GetPodByRequestID(context.Background(), "1")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, pod.Name, EqualTo{"blueio-a"})
+
+	sessions, err := k.ListSessions(context.Background())
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, len(sessions), EqualTo{2})
+}
+
+func TestCachedPodIsCopied(t *testing.T) {
+	k := cachedClient(cachedPod("blueio-a", "1", "abc"))
+	pod, _ := k.GetPodByName(context.Background(), "blueio-a")
+	pod.Labels[defaultSeleniumSessionIDField] = "changed"
+	pod, _ = k.GetPodByName(context.Background(), "blueio-a")
+	AssertThat(t, pod.Labels[defaultSeleniumSessionIDField], EqualTo{"abc"})
+}
+
+func TestCacheNotSynced(t *testing.T) {
+	k := cachedClient(cachedPod("blueio-a", "1", "abc"))
+	k.pods.synced = make(chan struct{})
+	AssertThat(t, k.Synced(), Is{false})
+	AssertThat(t, k.cachedPod("blueio-a") == nil, Is{true})
+}
+
+func TestStartCache(t *testing.T) {
+	watched := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/default/pods", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "" {
+			w.Write([]byte(`{"kind": "PodList", "apiVersion": "v1", "metadata": {"resourceVersion": "1"}, "items": [{"metadata": {"name": "blueio-a"}}]}`))
+			return
+		}
+		select {
+		case <-watched:
+			<-r.Context().Done()
+		default:
+			close(watched)
+			w.Write([]byte(`{"type": "ADDED", "object": {"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "blueio-b"}}}`))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	k := execClient(t, srv.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	k.StartCache(ctx, 0)
+	AssertThat(t, k.WaitForCacheSync(ctx), Is{true})
+	AssertThat(t, k.cachedPod("blueio-a") != nil, Is{true})
+
+	<-watched
+	for k.cachedPod("blueio-b") == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	for k.Synced() {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,156 @@
+package kube
+
+import (
+	"context"
+	"log"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+type DeleteOptions struct {
+	// GracePeriod overrides the termination grace period of the pod, zero
+	// deletes it immediately. It is rounded up to whole seconds.
+	GracePeriod *time.Duration
+	// Propagation defaults to the policy of the API server.
+	Propagation metav1.DeletionPropagation
+	// UID only deletes the pod if it is still the given one and not a pod
+	// recreated with the same name.
+	UID types.UID
+	// Wait blocks until the pod is removed from the API server.
+	Wait bool
+	// IgnoreNotFound treats a pod that is already gone as deleted.
+	IgnoreNotFound bool
+}
+
+func (opts DeleteOptions) apiOptions() *metav1.DeleteOptions {
+	result := &metav1.DeleteOptions{}
+	if opts.GracePeriod != nil {
+		// rounding down would turn a short grace period into an immediate kill
+		seconds := int64((*opts.GracePeriod + time.Second - 1) / time.Second)
+		result.GracePeriodSeconds = &seconds
+	}
+	if opts.Propagation != "" {
+		propagation := opts.Propagation
+		result.PropagationPolicy = &propagation
+	}
+	if opts.UID != "" {
+		result.Preconditions = metav1.NewUIDPreconditions(string(opts.UID))
+	}
+	return result
+}
+
+func (k *KubeClient) DeletePod(ctx context.Context, name string, opts DeleteOptions) error {
+	if k.debug {
+		log.Printf("DEBUG: DeletePod: name, opts: %s, %+v", name, opts)
+	}
+
+	err := k.deletePod(ctx, name, opts.apiOptions())
+	if err == nil || apierrors.IsNotFound(err) {
+		k.stopPortForward(name)
+	}
+	if apierrors.IsNotFound(err) && opts.IgnoreNotFound {
+		return nil
+	}
+	if err != nil || !opts.Wait {
+		return err
+	}
+	return k.waitDeleted(ctx, name, opts.UID)
+}
+
+// DeletePods deletes the pods matching the selector and returns the names of
+// the deleted ones. Each pod is deleted with its UID as precondition, pods
+// that disappear in the meantime are skipped.
+func (k *KubeClient) DeletePods(ctx context.Context, selector map[string]string, opts DeleteOptions) ([]string, error) {
+	if k.debug {
+		log.Printf("DEBUG: DeletePods: selector, opts: %v, %+v", selector, opts)
+	}
+
+	list, err := k.listPods(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(selector).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, pod := range list.Items {
+		podOpts := opts
+		podOpts.UID = pod.UID
+		podOpts.Wait = false
+		err := k.deletePod(ctx, pod.Name, podOpts.apiOptions())
+		if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		k.stopPortForward(pod.Name)
+		deleted = append(deleted, pod.Name)
+	}
+	if opts.Wait {
+		for _, pod := range list.Items {
+			if err := k.waitDeleted(ctx, pod.Name, pod.UID); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// waitDeleted returns once no pod with the name, or with the name and the
+// UID when it is set, exists.
+func (k *KubeClient) waitDeleted(ctx context.Context, name string, uid types.UID) error {
+	for {
+		pod, err := k.getPod(ctx, name)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if uid != "" && pod.UID != uid {
+			return nil
+		}
+
+		w, err := k.watchPods(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+			ResourceVersion: pod.ResourceVersion,
+		})
+		if err != nil {
+			return err
+		}
+		deleted, err := waitPodDeleted(ctx, w)
+		w.Stop()
+		if deleted || err != nil {
+			return err
+		}
+		if k.debug {
+			log.Printf("DEBUG: waitDeleted: watch for %s expired, restarting", name)
+		}
+	}
+}
+
+// waitPodDeleted returns false, nil when the watch ends before the deletion.
+func waitPodDeleted(ctx context.Context, w watch.Interface) (bool, error) {
+	for {
+		select {
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return false, nil
+			}
+			if e.Type == watch.Deleted {
+				return true, nil
+			}
+			if e.Type == watch.Error {
+				return false, apierrors.FromObject(e.Object)
+			}
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
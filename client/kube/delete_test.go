@@ -0,0 +1,46 @@
+package kube
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeleteAPIOptions(t *testing.T) {
+	grace := 1500 * time.Millisecond
+	opts := DeleteOptions{GracePeriod: &grace, Propagation: metav1.DeletePropagationForeground, UID: "123"}.apiOptions()
+	AssertThat(t, *opts.GracePeriodSeconds, EqualTo{int64(2)})
+	AssertThat(t, *opts.PropagationPolicy, EqualTo{metav1.DeletePropagationForeground})
+	AssertThat(t, string(*opts.Preconditions.UID), EqualTo{"123"})
+
+	for _, c := range []struct {
+		grace   time.Duration
+		seconds int64
+	}{{0, 0}, {500 * time.Millisecond, 1}, {time.Second, 1}} {
+		opts = DeleteOptions{GracePeriod: &c.grace}.apiOptions()
+		AssertThat(t, *opts.GracePeriodSeconds, EqualTo{c.seconds})
+	}
+
+	opts = DeleteOptions{}.apiOptions()
+	AssertThat(t, opts.GracePeriodSeconds == nil, Is{true})
+	AssertThat(t, opts.Preconditions == nil, Is{true})
+}
+
+func TestDeleteNotFound(t *testing.T) {
+	srv := apiServer()
+	defer srv.Close()
+	k := testClient(t, srv.URL)
+	AssertThat(t, k.DeletePod(context.Background(), "missing", DeleteOptions{}), Not{nil})
+	AssertThat(t, k.DeletePod(context.Background(), "missing", DeleteOptions{IgnoreNotFound: true}), Is{nil})
+}
+
+func TestDeleteWait(t *testing.T) {
+	srv := apiServer()
+	defer srv.Close()
+	k := testClient(t, srv.URL)
+	AssertThat(t, k.DeletePod(context.Background(), "deleted", DeleteOptions{Wait: true}), Is{nil})
+}
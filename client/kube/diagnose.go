@@ -0,0 +1,235 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const diagnoseLogLines = 100
+
+// Diagnosis explains why a session pod failed, it is meant to be returned
+// as is to the client whose session could not be created.
+type Diagnosis struct {
+	Pod        string               `json:"pod"`
+	Namespace  string               `json:"namespace"`
+	Node       string               `json:"node,omitempty"`
+	Phase      string               `json:"phase"`
+	Reason     string               `json:"reason,omitempty"`
+	Message    string               `json:"message,omitempty"`
+	Containers []ContainerDiagnosis `json:"containers"`
+	Events     []EventDiagnosis     `json:"events"`
+}
+
+type ContainerDiagnosis struct {
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	// State is waiting, running or terminated.
+	State    string `json:"state"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	ExitCode int32  `json:"exitCode,omitempty"`
+	// LastReason tells why the previous instance of a restarted container
+	// terminated, e.g. OOMKilled.
+	LastReason   string `json:"lastReason,omitempty"`
+	LastExitCode int32  `json:"lastExitCode,omitempty"`
+	// Logs are the last lines of a terminated container.
+	Logs string `json:"logs,omitempty"`
+	// PreviousLogs are the last lines of the previous instance of a
+	// restarted container.
+	PreviousLogs string `json:"previousLogs,omitempty"`
+}
+
+type EventDiagnosis struct {
+	Type    string    `json:"type"`
+	Reason  string    `json:"reason"`
+	Message string    `json:"message"`
+	Count   int32     `json:"count"`
+	Time    time.Time `json:"time"`
+}
+
+// SessionPodError is returned by StartSessionPod when the pod does not get
+// ready, Diagnosis tells why and is nil when the pod could not be read.
+type SessionPodError struct {
+	Err       error
+	Diagnosis *Diagnosis
+}
+
+func (e *SessionPodError) Error() string {
+	if e.Diagnosis == nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v (%s)", e.Err, e.Diagnosis.Summary())
+}
+
+// Summary is a one line version of the diagnosis with the phase, the
+// reasons of the containers that are not ready and the warning events.
+func (d *Diagnosis) Summary() string {
+	phase := d.Phase
+	if d.Reason != "" {
+		phase += ": " + d.Reason
+	}
+	if d.Message != "" {
+		phase += ": " + d.Message
+	}
+	parts := []string{fmt.Sprintf("pod %s is %s", d.Pod, phase)}
+	for _, c := range d.Containers {
+		if c.Ready {
+			continue
+		}
+		s := fmt.Sprintf("container %s is %s", c.Name, c.State)
+		if c.Reason != "" {
+			s += ": " + c.Reason
+		}
+		if c.LastReason != "" {
+			s += fmt.Sprintf(", last terminated: %s (exit code %d)", c.LastReason, c.LastExitCode)
+		}
+		parts = append(parts, s)
+	}
+	for _, e := range d.Events {
+		if e.Type == apiv1.EventTypeWarning {
+			parts = append(parts, fmt.Sprintf("%s: %s", e.Reason, e.Message))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Diagnose collects the state, events and logs of a pod. Events and logs
+// that can not be read are left out of the report.
+func (k *KubeClient) Diagnose(ctx context.Context, name string) (*Diagnosis, error) {
+	if k.debug {
+		log.Printf("DEBUG: Diagnose: name: %s", name)
+	}
+
+	pod, err := k.getPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	d := diagnosePod(pod)
+
+	for i := range d.Containers {
+		c := &d.Containers[i]
+		if c.State == "terminated" {
+			c.Logs = k.tailLogs(ctx, name, c.Name, false)
+		}
+		if c.RestartCount > 0 {
+			c.PreviousLogs = k.tailLogs(ctx, name, c.Name, true)
+		}
+	}
+
+	events, err := k.podEventList(ctx, name)
+	if err != nil {
+		if k.debug {
+			log.Printf("DEBUG: Diagnose: cannot list events of %s: %s", name, err)
+		}
+	} else {
+		d.Events = diagnoseEvents(events)
+	}
+	return d, nil
+}
+
+func diagnosePod(pod *apiv1.Pod) *Diagnosis {
+	d := &Diagnosis{
+		Pod:        pod.Name,
+		Namespace:  pod.Namespace,
+		Node:       pod.Spec.NodeName,
+		Phase:      string(pod.Status.Phase),
+		Reason:     pod.Status.Reason,
+		Message:    pod.Status.Message,
+		Containers: []ContainerDiagnosis{},
+		Events:     []EventDiagnosis{},
+	}
+	statuses := make(map[string]apiv1.ContainerStatus)
+	for _, status := range pod.Status.ContainerStatuses {
+		statuses[status.Name] = status
+	}
+	for _, container := range pod.Spec.Containers {
+		c := ContainerDiagnosis{Name: container.Name, Image: container.Image, State: "waiting"}
+		status, ok := statuses[container.Name]
+		if ok {
+			c.Ready = status.Ready
+			c.RestartCount = status.RestartCount
+			switch state := status.State; {
+			case state.Waiting != nil:
+				c.Reason, c.Message = state.Waiting.Reason, state.Waiting.Message
+			case state.Running != nil:
+				c.State = "running"
+			case state.Terminated != nil:
+				c.State = "terminated"
+				c.Reason, c.Message = state.Terminated.Reason, state.Terminated.Message
+				c.ExitCode = state.Terminated.ExitCode
+			}
+			if last := status.LastTerminationState.Terminated; last != nil {
+				c.LastReason = last.Reason
+				c.LastExitCode = last.ExitCode
+			}
+		}
+		d.Containers = append(d.Containers, c)
+	}
+	return d
+}
+
+func (k *KubeClient) tailLogs(ctx context.Context, name, container string, previous bool) string {
+	lines := int64(diagnoseLogLines)
+	logs, err := k.podLogs(ctx, name, &apiv1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+		TailLines: &lines,
+	})
+	if err != nil {
+		if k.debug {
+			log.Printf("DEBUG: Diagnose: cannot get logs of %s/%s: %s", name, container, err)
+		}
+		return ""
+	}
+	return string(logs)
+}
+
+func (k *KubeClient) podEventList(ctx context.Context, name string) (*apiv1.EventList, error) {
+	list := &apiv1.EventList{}
+	err := k.restClient().Get().
+		Namespace(k.namespace).
+		Resource("events").
+		VersionedParams(&metav1.ListOptions{
+			FieldSelector: fields.Set{
+				"involvedObject.kind": "Pod",
+				"involvedObject.name": name,
+			}.AsSelector().String(),
+		}, scheme.ParameterCodec).
+		Context(ctx).
+		Timeout(k.timeouts.List).
+		Do().
+		Into(list)
+	return list, err
+}
+
+func diagnoseEvents(list *apiv1.EventList) []EventDiagnosis {
+	events := []EventDiagnosis{}
+	for _, e := range list.Items {
+		t := e.LastTimestamp.Time
+		if t.IsZero() {
+			t = e.EventTime.Time
+		}
+		events = append(events, EventDiagnosis{
+			Type:    e.Type,
+			Reason:  e.Reason,
+			Message: e.Message,
+			Count:   e.Count,
+			Time:    t,
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events
+}
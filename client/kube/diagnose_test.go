@@ -0,0 +1,90 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiagnosePod(t *testing.T) {
+	pod := sessionPod()
+	pod.Namespace = "browsers"
+	pod.Spec.NodeName = "node-1"
+	pod.Spec.Containers[0].Name = "browser"
+	pod.Spec.Containers[0].Image = "blueio/images:chrome_70.0"
+	pod.Spec.Containers = append(pod.Spec.Containers, apiv1.Container{Name: "video"})
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name:         "browser",
+		RestartCount: 1,
+		State: apiv1.ContainerState{
+			Waiting: &apiv1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+		},
+		LastTerminationState: apiv1.ContainerState{
+			Terminated: &apiv1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+		},
+	}}
+
+	d := diagnosePod(pod)
+	AssertThat(t, d.Node, EqualTo{"node-1"})
+	AssertThat(t, d.Containers, EqualTo{[]ContainerDiagnosis{
+		{
+			Name:         "browser",
+			Image:        "blueio/images:chrome_70.0",
+			RestartCount: 1,
+			State:        "waiting",
+			Reason:       "CrashLoopBackOff",
+			LastReason:   "OOMKilled",
+			LastExitCode: 137,
+		},
+		{Name: "video", State: "waiting"},
+	}})
+}
+
+func TestDiagnoseEvents(t *testing.T) {
+	first := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := diagnoseEvents(&apiv1.EventList{Items: []apiv1.Event{
+		{Type: "Warning", Reason: "Failed", Message: "ErrImagePull", Count: 3, LastTimestamp: metav1.NewTime(first.Add(time.Minute))},
+		{Type: "Warning", Reason: "FailedScheduling", Message: "0/3 nodes are available", Count: 1, LastTimestamp: metav1.NewTime(first)},
+	}})
+	AssertThat(t, len(events), EqualTo{2})
+	AssertThat(t, events[0].Reason, EqualTo{"FailedScheduling"})
+
+	data, err := json.Marshal(events[0])
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, string(data), EqualTo{`{"type":"Warning","reason":"FailedScheduling","message":"0/3 nodes are available","count":1,"time":"2019-01-02T03:04:05Z"}`})
+}
+
+func TestDiagnosisSummary(t *testing.T) {
+	d := &Diagnosis{
+		Pod:   "blueio-1",
+		Phase: "Pending",
+		Containers: []ContainerDiagnosis{
+			{Name: "browser", State: "waiting", Reason: "CrashLoopBackOff", LastReason: "OOMKilled", LastExitCode: 137},
+			{Name: "video", State: "running", Ready: true},
+		},
+		Events: []EventDiagnosis{
+			{Type: "Normal", Reason: "Scheduled", Message: "assigned to node-1"},
+			{Type: "Warning", Reason: "BackOff", Message: "back-off restarting failed container"},
+		},
+	}
+	AssertThat(t, d.Summary(), EqualTo{"pod blueio-1 is Pending; container browser is waiting: CrashLoopBackOff, last terminated: OOMKilled (exit code 137); BackOff: back-off restarting failed container"})
+
+	err := &SessionPodError{Err: errors.New("not ready"), Diagnosis: d}
+	AssertThat(t, err.Error(), EqualTo{"not ready (" + d.Summary() + ")"})
+}
+
+func TestSessionPodErrorWithoutPod(t *testing.T) {
+	srv := apiServer()
+	defer srv.Close()
+	notReady := errors.New("not ready")
+	err := testClient(t, srv.URL).sessionPodError(context.Background(), "missing", notReady)
+	AssertThat(t, err.(*SessionPodError).Diagnosis == nil, Is{true})
+	AssertThat(t, err.Error(), EqualTo{"not ready"})
+}
@@ -0,0 +1,141 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// exec runs cmd inside the pod and streams its standard descriptors. An empty
+// container name stands for the browser container, the first one of the pod.
+func (k *KubeClient) exec(ctx context.Context, name, container string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if k.debug {
+		log.Printf("DEBUG: exec: name, container, cmd: %s, %s, %v", name, container, cmd)
+	}
+
+	if container == "" {
+		var err error
+		container, err = k.browserContainer(ctx, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	req := k.clientset.CoreV1().RESTClient().Get().
+		Resource("pods").
+		Name(name).
+		Namespace(k.namespace).
+		SubResource("exec").
+		VersionedParams(&apiv1.PodExecOptions{
+			Container: container,
+			Command:   cmd,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			Stderr:    stderr != nil,
+		}, scheme.ParameterCodec)
+
+	conn, err := k.dialStream(ctx, req.URL())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- streamExec(conn, stdin, stdout, stderr)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// closing the connection ends the stream, which must not touch the
+		// caller's descriptors after exec returns
+		conn.Close()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// browserContainer names the first container of the pod when it has a
+// sidecar, the API server needs a container name then.
+func (k *KubeClient) browserContainer(ctx context.Context, name string) (string, error) {
+	pod, err := k.getPod(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if len(pod.Spec.Containers) > 1 {
+		return pod.Spec.Containers[0].Name, nil
+	}
+	return "", nil
+}
+
+func (k *KubeClient) Exec(ctx context.Context, name string, cmd []string) (*blueclient.ExecResult, error) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	result := &blueclient.ExecResult{}
+	err := k.exec(ctx, name, "", cmd, nil, stdout, stderr)
+	if exitErr, ok := err.(*exitError); ok {
+		result.ExitCode = exitErr.code
+	} else if err != nil {
+		return nil, err
+	}
+	result.Stdout = stdout.Bytes()
+	result.Stderr = stderr.Bytes()
+	return result, nil
+}
+
+func (k *KubeClient) CopyTo(ctx context.Context, name, dstPath string, content io.Reader) error {
+	if k.debug {
+		log.Printf("DEBUG: CopyTo: name, dstPath: %s, %s", name, dstPath)
+	}
+
+	// exec streams can not close stdin, so the archive is measured first and
+	// no more than its size is read
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	stderr := new(bytes.Buffer)
+	cmd := []string{"sh", "-c", `head -c "$1" | tar xf - -C "$2"`, "sh", strconv.Itoa(len(data)), dstPath}
+	err = k.exec(ctx, name, "", cmd, bytes.NewReader(data), new(bytes.Buffer), stderr)
+	if err != nil {
+		return execError(err, stderr)
+	}
+	return nil
+}
+
+func (k *KubeClient) CopyFrom(ctx context.Context, name, srcPath string) (io.ReadCloser, error) {
+	if k.debug {
+		log.Printf("DEBUG: CopyFrom: name, srcPath: %s, %s", name, srcPath)
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		stderr := new(bytes.Buffer)
+		cmd := []string{"tar", "cf", "-", "-C", path.Dir(srcPath), path.Base(srcPath)}
+		err := k.exec(ctx, name, "", cmd, nil, w, stderr)
+		if err != nil {
+			err = execError(err, stderr)
+		}
+		w.CloseWithError(err)
+	}()
+	return r, nil
+}
+
+func execError(err error, stderr *bytes.Buffer) error {
+	msg := strings.TrimSpace(stderr.String())
+	if msg == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %s", err, msg)
+}
@@ -0,0 +1,242 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+type ExposeMode string
+
+const (
+	// ExposeService creates a Service owned by the pod, which is garbage
+	// collected along with it.
+	ExposeService ExposeMode = "service"
+	// ExposePortForward forwards local ports of the process to the pod.
+	ExposePortForward ExposeMode = "port-forward"
+
+	portForwardAddress = "127.0.0.1"
+)
+
+type ExposeOptions struct {
+	Mode ExposeMode
+	// ServiceType is ClusterIP by default, NodePort services are reached
+	// on NodeAddress.
+	ServiceType apiv1.ServiceType
+	// NodeAddress defaults to the IP of the node running the pod.
+	NodeAddress string
+	// Ports default to the container ports of the pod, i.e. WebDriver and
+	// VNC for session pods.
+	Ports []int
+}
+
+// Expose makes the ports of a session pod reachable from outside the
+// cluster network. The endpoint maps every exposed container port to the
+// port it is reachable on.
+func (k *KubeClient) Expose(ctx context.Context, name string, opts ExposeOptions) (*blueclient.Endpoint, error) {
+	if k.debug {
+		log.Printf("DEBUG: Expose: name, opts: %s, %+v", name, opts)
+	}
+
+	pod, err := k.getPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	ports := opts.Ports
+	if len(ports) == 0 {
+		ports = podPorts(pod)
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("pod %s exposes no ports", name)
+	}
+
+	switch opts.Mode {
+	case ExposeService, "":
+		return k.exposeService(ctx, pod, ports, opts)
+	case ExposePortForward:
+		return k.portForward(ctx, pod, ports)
+	default:
+		return nil, fmt.Errorf("unknown expose mode: %s", opts.Mode)
+	}
+}
+
+// Unexpose stops the port-forward and deletes the Service of the pod, if
+// any. Deleting the pod does the same.
+func (k *KubeClient) Unexpose(ctx context.Context, name string) error {
+	if k.debug {
+		log.Printf("DEBUG: Unexpose: name: %s", name)
+	}
+
+	k.stopPortForward(name)
+	err := k.restClient().Delete().
+		Namespace(k.namespace).
+		Resource("services").
+		Name(name).
+		Context(ctx).
+		Timeout(k.timeouts.Delete).
+		Do().
+		Error()
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func podPorts(pod *apiv1.Pod) []int {
+	var ports []int
+	for _, c := range pod.Spec.Containers {
+		for _, port := range c.Ports {
+			ports = append(ports, int(port.ContainerPort))
+		}
+	}
+	return ports
+}
+
+// sessionSelector selects the pod by its request ID, which is unique per
+// session.
+func sessionSelector(pod *apiv1.Pod) (map[string]string, error) {
+	requestID := pod.Labels[blueclient.RequestIDLabel]
+	if requestID == "" {
+		return nil, fmt.Errorf("pod %s has no %s label to select it", pod.Name, blueclient.RequestIDLabel)
+	}
+	selector := blueclient.ManagedSelector()
+	selector[blueclient.RequestIDLabel] = requestID
+	return selector, nil
+}
+
+// ownedByPod makes the garbage collector delete the object with the pod.
+func ownedByPod(pod *apiv1.Pod) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Name:       pod.Name,
+		UID:        pod.UID,
+		Controller: &controller,
+	}}
+}
+
+func buildSessionService(pod *apiv1.Pod, ports []int, serviceType apiv1.ServiceType) (*apiv1.Service, error) {
+	selector, err := sessionSelector(pod)
+	if err != nil {
+		return nil, err
+	}
+	if serviceType == "" {
+		serviceType = apiv1.ServiceTypeClusterIP
+	}
+
+	svc := &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod.Name,
+			Labels:          selector,
+			OwnerReferences: ownedByPod(pod),
+		},
+		Spec: apiv1.ServiceSpec{
+			Type:     serviceType,
+			Selector: selector,
+		},
+	}
+	for _, port := range ports {
+		svc.Spec.Ports = append(svc.Spec.Ports, apiv1.ServicePort{
+			Name:       fmt.Sprintf("port-%d", port),
+			Port:       int32(port),
+			TargetPort: intstr.FromInt(port),
+		})
+	}
+	return svc, nil
+}
+
+func serviceEndpoint(svc *apiv1.Service, nodeAddress string) *blueclient.Endpoint {
+	if svc.Spec.Type == apiv1.ServiceTypeNodePort {
+		ep := &blueclient.Endpoint{IP: nodeAddress, Ports: make(map[int]int)}
+		for _, port := range svc.Spec.Ports {
+			ep.Ports[int(port.Port)] = int(port.NodePort)
+		}
+		return ep
+	}
+	ep := &blueclient.Endpoint{IP: svc.Spec.ClusterIP, Ports: make(map[int]int)}
+	for _, port := range svc.Spec.Ports {
+		ep.Ports[int(port.Port)] = int(port.Port)
+	}
+	return ep
+}
+
+func (k *KubeClient) exposeService(ctx context.Context, pod *apiv1.Pod, ports []int, opts ExposeOptions) (*blueclient.Endpoint, error) {
+	svc, err := buildSessionService(pod, ports, opts.ServiceType)
+	if err != nil {
+		return nil, err
+	}
+	result := &apiv1.Service{}
+	err = k.restClient().Post().
+		Namespace(k.namespace).
+		Resource("services").
+		Body(svc).
+		Context(ctx).
+		Timeout(k.timeouts.Create).
+		Do().
+		Into(result)
+	if err != nil {
+		return nil, err
+	}
+	nodeAddress := opts.NodeAddress
+	if nodeAddress == "" {
+		nodeAddress = pod.Status.HostIP
+	}
+	return serviceEndpoint(result, nodeAddress), nil
+}
+
+// portForward returns once the local ports listen. The forward lasts until
+// Unexpose or the deletion of the pod, ctx only bounds setting it up.
+func (k *KubeClient) portForward(ctx context.Context, pod *apiv1.Pod, ports []int) (*blueclient.Endpoint, error) {
+	fw, err := k.newForwarder(pod.Name, ports)
+	if err != nil {
+		return nil, err
+	}
+	if err := fw.check(ctx); err != nil {
+		fw.close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("port-forward to %s: %v", pod.Name, err)
+	}
+
+	stop := make(chan struct{})
+	k.mu.Lock()
+	if k.forwards == nil {
+		k.forwards = make(map[string]chan struct{})
+	}
+	if previous, ok := k.forwards[pod.Name]; ok {
+		close(previous)
+	}
+	k.forwards[pod.Name] = stop
+	k.mu.Unlock()
+	go func() {
+		fw.serve(stop)
+		if k.debug {
+			log.Printf("DEBUG: portForward: forward to %s stopped", pod.Name)
+		}
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		if k.forwards[pod.Name] == stop {
+			delete(k.forwards, pod.Name)
+		}
+	}()
+
+	return &blueclient.Endpoint{IP: portForwardAddress, Ports: fw.localPorts()}, nil
+}
+
+func (k *KubeClient) stopPortForward(name string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if stop, ok := k.forwards[name]; ok {
+		close(stop)
+		delete(k.forwards, name)
+	}
+}
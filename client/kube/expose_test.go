@@ -0,0 +1,52 @@
+package kube
+
+import (
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestBuildSessionService(t *testing.T) {
+	pod, _ := BuildSessionPod("42", "blueio/images:chrome_70.0", &SessionOptions{EnableVNC: true})
+	pod.Name = "blueio-abc"
+	pod.UID = "123"
+	ports := podPorts(pod)
+	AssertThat(t, ports, EqualTo{[]int{4444, 5900}})
+
+	svc, err := buildSessionService(pod, ports, "")
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, svc.Name, EqualTo{"blueio-abc"})
+	AssertThat(t, svc.Spec.Type, EqualTo{apiv1.ServiceTypeClusterIP})
+	AssertThat(t, svc.Spec.Selector[blueclient.RequestIDLabel], EqualTo{"42"})
+	AssertThat(t, string(svc.OwnerReferences[0].UID), EqualTo{"123"})
+	AssertThat(t, len(svc.Spec.Ports), EqualTo{2})
+}
+
+func TestBuildSessionServiceWithoutRequestID(t *testing.T) {
+	_, err := buildSessionService(sessionPod(), []int{4444}, "")
+	AssertThat(t, err, Not{nil})
+}
+
+func TestServiceEndpoint(t *testing.T) {
+	svc := &apiv1.Service{Spec: apiv1.ServiceSpec{
+		Type:      apiv1.ServiceTypeNodePort,
+		ClusterIP: "10.0.0.10",
+		Ports:     []apiv1.ServicePort{{Port: 4444, NodePort: 31444}},
+	}}
+	AssertThat(t, *serviceEndpoint(svc, "192.168.1.5"), EqualTo{blueclient.Endpoint{IP: "192.168.1.5", Ports: map[int]int{4444: 31444}}})
+
+	svc.Spec.Type = apiv1.ServiceTypeClusterIP
+	AssertThat(t, serviceEndpoint(svc, "192.168.1.5").Address(4444), EqualTo{"10.0.0.10:4444"})
+}
+
+func TestStopPortForward(t *testing.T) {
+	stop := make(chan struct{})
+	k := &KubeClient{forwards: map[string]chan struct{}{"blueio-abc": stop}}
+	k.stopPortForward("blueio-abc")
+	k.stopPortForward("blueio-abc")
+	_, open := <-stop
+	AssertThat(t, open, Is{false})
+}
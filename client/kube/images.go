@@ -0,0 +1,294 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// pullLabel groups the pods of a pull, they are not session pods and
+	// do not carry the managed label.
+	pullLabel     = "blueio.pull"
+	pullContainer = "pull"
+)
+
+var pullErrors = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+	"ImageInspectError": true,
+}
+
+// buildPullPod runs the image on the node with a command that exits at once,
+// the kubelet pulls the image to do so with the given secrets.
+func buildPullPod(image, node, pullID string, secrets []string) *apiv1.Pod {
+	automount := false
+	pod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "blueio-pull-",
+			Labels:       map[string]string{pullLabel: pullID},
+		},
+		Spec: apiv1.PodSpec{
+			NodeName:                     node,
+			RestartPolicy:                apiv1.RestartPolicyNever,
+			AutomountServiceAccountToken: &automount,
+			Tolerations:                  []apiv1.Toleration{{Operator: apiv1.TolerationOpExists}},
+			Containers: []apiv1.Container{{
+				Name:            pullContainer,
+				Image:           image,
+				ImagePullPolicy: apiv1.PullIfNotPresent,
+				Command:         []string{"/bin/sh", "-c", "exit 0"},
+			}},
+		},
+	}
+	for _, secret := range secrets {
+		pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, apiv1.LocalObjectReference{Name: secret})
+	}
+	return pod
+}
+
+// pullStatus tells how far the pull pod got, the pull is over once the
+// kubelet gave up or went past the creation of the container, whether the
+// container runs or not.
+func pullStatus(pod *apiv1.Pod) (status string, done bool, err string) {
+	for _, s := range pod.Status.ContainerStatuses {
+		switch {
+		case s.State.Waiting != nil && pullErrors[s.State.Waiting.Reason]:
+			return s.State.Waiting.Reason, true, s.State.Waiting.Message
+		case s.State.Waiting != nil && (s.State.Waiting.Reason == "" || s.State.Waiting.Reason == "ContainerCreating"):
+			return "Pulling", false, ""
+		default:
+			return "Pulled", true, ""
+		}
+	}
+	if pod.Status.Phase == apiv1.PodFailed {
+		return string(pod.Status.Phase), true, pod.Status.Message
+	}
+	return string(pod.Status.Phase), false, ""
+}
+
+func nodeReady(node *apiv1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, c := range node.Status.Conditions {
+		if c.Type == apiv1.NodeReady {
+			return c.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func (k *KubeClient) listNodes(ctx context.Context) (*apiv1.NodeList, error) {
+	list := &apiv1.NodeList{}
+	err := k.restClient().Get().
+		Resource("nodes").
+		Context(ctx).
+		Timeout(k.timeouts.List).
+		Do().
+		Into(list)
+	return list, err
+}
+
+// PullImage pulls the image on every ready node with a pull pod per node.
+// The pods are deleted once the pull is over, nodes that are not done
+// within the pull timeout report an error.
+func (k *KubeClient) PullImage(ctx context.Context, image string) (<-chan blueclient.PullProgress, error) {
+	if k.debug {
+		log.Printf("DEBUG: PullImage: image: %s", image)
+	}
+
+	nodes, err := k.listNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pullID := strconv.FormatInt(time.Now().UnixNano(), 36)
+	selector := metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{pullLabel: pullID}).String(),
+	}
+	// the watch starts first not to miss the pods pulling at once
+	w, err := k.watchPods(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[string]string)
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if !nodeReady(node) {
+			continue
+		}
+		pod, err := k.createPod(ctx, buildPullPod(image, node.Name, pullID, k.pullSecrets))
+		if err != nil {
+			w.Stop()
+			k.deletePullPods(pullID)
+			return nil, err
+		}
+		pending[pod.Name] = node.Name
+	}
+	if len(pending) == 0 {
+		w.Stop()
+		return nil, fmt.Errorf("no ready node to pull %s on", image)
+	}
+
+	out := make(chan blueclient.PullProgress)
+	go func() {
+		defer close(out)
+		defer k.deletePullPods(pullID)
+		k.pullProgress(ctx, image, w, selector, pending, out)
+	}()
+	return out, nil
+}
+
+func (k *KubeClient) pullProgress(ctx context.Context, image string, w watch.Interface, selector metav1.ListOptions, pending map[string]string, out chan<- blueclient.PullProgress) {
+	pullCtx, cancel := context.WithTimeout(ctx, k.timeouts.Pull)
+	defer cancel()
+	last := make(map[string]string)
+	send := func(p blueclient.PullProgress) bool {
+		select {
+		case out <- p:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	fail := func(reason string) {
+		for name, node := range pending {
+			delete(pending, name)
+			send(blueclient.PullProgress{Image: image, Node: node, Error: reason, Time: time.Now()})
+		}
+	}
+	// update reports the pod once its status changes and returns false when
+	// the receiver is gone.
+	update := func(pod *apiv1.Pod, deleted bool) bool {
+		node, ok := pending[pod.Name]
+		if !ok {
+			return true
+		}
+		status, done, pullErr := pullStatus(pod)
+		if deleted && !done {
+			done, pullErr = true, "pull pod deleted"
+		}
+		if status == last[pod.Name] && !done {
+			return true
+		}
+		last[pod.Name] = status
+		if done {
+			delete(pending, pod.Name)
+			if pullErr == "" && status != "Pulled" {
+				pullErr = status
+			}
+		}
+		return send(blueclient.PullProgress{Image: image, Node: node, Status: status, Error: pullErr, Time: time.Now()})
+	}
+	// resync reports the listed pods, pending pods missing from the list
+	// were deleted
+	resync := func(list *apiv1.PodList) bool {
+		listed := make(map[string]bool)
+		for i := range list.Items {
+			listed[list.Items[i].Name] = true
+			if !update(&list.Items[i], false) {
+				return false
+			}
+		}
+		for name, node := range pending {
+			if listed[name] {
+				continue
+			}
+			delete(pending, name)
+			if !send(blueclient.PullProgress{Image: image, Node: node, Error: "pull pod deleted", Time: time.Now()}) {
+				return false
+			}
+		}
+		return true
+	}
+	defer func() {
+		w.Stop()
+	}()
+	for len(pending) > 0 {
+		select {
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				// watches expire, the pods are listed again not to miss
+				// what happened in between
+				list, err := k.listPods(pullCtx, selector)
+				if err == nil {
+					opts := selector
+					opts.ResourceVersion = list.ResourceVersion
+					w, err = k.watchPods(pullCtx, opts)
+				}
+				if err != nil {
+					w = watch.NewEmptyWatch()
+					fail(err.Error())
+					return
+				}
+				if !resync(list) {
+					return
+				}
+				continue
+			}
+			pod, ok := e.Object.(*apiv1.Pod)
+			if !ok {
+				continue
+			}
+			if !update(pod, e.Type == watch.Deleted) {
+				return
+			}
+		case <-pullCtx.Done():
+			if ctx.Err() == nil {
+				fail(fmt.Sprintf("pull not done within %v", k.timeouts.Pull))
+				return
+			}
+			for _, node := range pending {
+				log.Printf("WARNING: pull of %s on %s interrupted: %v", image, node, ctx.Err())
+			}
+			return
+		}
+	}
+}
+
+func (k *KubeClient) deletePullPods(pullID string) {
+	_, err := k.DeletePods(context.Background(), map[string]string{pullLabel: pullID}, DeleteOptions{})
+	if err != nil {
+		log.Printf("WARNING: cannot delete pull pods %s: %v", pullID, err)
+	}
+}
+
+// ListImages returns the browser images the nodes report, Kubernetes does
+// not tell when they were pulled nor whether they are used.
+func (k *KubeClient) ListImages(ctx context.Context) ([]blueclient.ImageInfo, error) {
+	if k.debug {
+		log.Printf("DEBUG: ListImages")
+	}
+
+	nodes, err := k.listNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	images := []blueclient.ImageInfo{}
+	for _, node := range nodes.Items {
+		for _, image := range node.Status.Images {
+			for _, name := range image.Names {
+				if strings.Contains(name, "@") {
+					continue
+				}
+				info := blueclient.ImageInfo{Image: name, Size: image.SizeBytes, Node: node.Name}
+				if blueclient.BrowserImage(&info) {
+					images = append(images, info)
+				}
+			}
+		}
+	}
+	return images, nil
+}
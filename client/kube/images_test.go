@@ -0,0 +1,111 @@
+package kube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestBuildPullPod(t *testing.T) {
+	pod := buildPullPod("blueio/images:chrome_70.0", "node-1", "abc", []string{"registry"})
+	AssertThat(t, pod.Labels, EqualTo{map[string]string{pullLabel: "abc"}})
+	AssertThat(t, pod.Spec.NodeName, EqualTo{"node-1"})
+	AssertThat(t, pod.Spec.RestartPolicy, EqualTo{apiv1.RestartPolicyNever})
+	AssertThat(t, pod.Spec.Containers[0].Image, EqualTo{"blueio/images:chrome_70.0"})
+	AssertThat(t, pod.Spec.ImagePullSecrets, EqualTo{[]apiv1.LocalObjectReference{{Name: "registry"}}})
+}
+
+func pullPod(state apiv1.ContainerState) *apiv1.Pod {
+	return &apiv1.Pod{Status: apiv1.PodStatus{
+		Phase:             apiv1.PodPending,
+		ContainerStatuses: []apiv1.ContainerStatus{{Name: pullContainer, State: state}},
+	}}
+}
+
+func TestPullStatus(t *testing.T) {
+	status, done, err := pullStatus(&apiv1.Pod{Status: apiv1.PodStatus{Phase: apiv1.PodPending}})
+	AssertThat(t, status, EqualTo{"Pending"})
+	AssertThat(t, done, Is{false})
+
+	status, done, _ = pullStatus(pullPod(apiv1.ContainerState{Waiting: &apiv1.ContainerStateWaiting{Reason: "ContainerCreating"}}))
+	AssertThat(t, status, EqualTo{"Pulling"})
+	AssertThat(t, done, Is{false})
+
+	status, done, err = pullStatus(pullPod(apiv1.ContainerState{Waiting: &apiv1.ContainerStateWaiting{Reason: "ErrImagePull", Message: "not found"}}))
+	AssertThat(t, status, EqualTo{"ErrImagePull"})
+	AssertThat(t, done, Is{true})
+	AssertThat(t, err, EqualTo{"not found"})
+
+	status, done, err = pullStatus(pullPod(apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 127}}))
+	AssertThat(t, status, EqualTo{"Pulled"})
+	AssertThat(t, done, Is{true})
+	AssertThat(t, err, EqualTo{""})
+}
+
+func TestNodeReady(t *testing.T) {
+	node := &apiv1.Node{Status: apiv1.NodeStatus{Conditions: []apiv1.NodeCondition{
+		{Type: apiv1.NodeReady, Status: apiv1.ConditionTrue},
+	}}}
+	AssertThat(t, nodeReady(node), Is{true})
+	node.Spec.Unschedulable = true
+	AssertThat(t, nodeReady(node), Is{false})
+	AssertThat(t, nodeReady(&apiv1.Node{}), Is{false})
+}
+
+func collectPull(k *KubeClient, w watch.Interface, pending map[string]string) []blueclient.PullProgress {
+	out := make(chan blueclient.PullProgress)
+	go func() {
+		defer close(out)
+		k.pullProgress(context.Background(), "blueio/images:chrome_70.0", w, metav1.ListOptions{}, pending, out)
+	}()
+	var progress []blueclient.PullProgress
+	for p := range out {
+		progress = append(progress, p)
+	}
+	return progress
+}
+
+func TestPullProgressTimeout(t *testing.T) {
+	srv := apiServer()
+	defer srv.Close()
+	k := testClient(t, srv.URL)
+	k.timeouts.Pull = 20 * time.Millisecond
+
+	w := watch.NewFake()
+	progress := collectPull(k, w, map[string]string{"blueio-pull-1": "node-1"})
+	AssertThat(t, len(progress), EqualTo{1})
+	AssertThat(t, progress[0].Node, EqualTo{"node-1"})
+	AssertThat(t, progress[0].Error, EqualTo{"pull not done within 20ms"})
+}
+
+func TestPullProgressPodDeletedWhileRewatching(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") != "" {
+			return
+		}
+		w.Write([]byte(`{"kind": "PodList", "apiVersion": "v1", "metadata": {"resourceVersion": "2"}, "items": [
+			{"metadata": {"name": "blueio-pull-1"}, "status": {"containerStatuses": [{"name": "pull", "state": {"terminated": {"exitCode": 0}}}]}}
+		]}`))
+	}))
+	defer srv.Close()
+	k := testClient(t, srv.URL)
+
+	w := watch.NewFake()
+	w.Stop()
+	progress := collectPull(k, w, map[string]string{"blueio-pull-1": "node-1", "blueio-pull-2": "node-2"})
+	AssertThat(t, len(progress), EqualTo{2})
+	AssertThat(t, progress[0].Node, EqualTo{"node-1"})
+	AssertThat(t, progress[0].Status, EqualTo{"Pulled"})
+	AssertThat(t, progress[1].Node, EqualTo{"node-2"})
+	AssertThat(t, progress[1].Error, EqualTo{"pull pod deleted"})
+}
@@ -0,0 +1,128 @@
+package kube
+
+import (
+	"context"
+	"log"
+
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const dnsPort = 53
+
+// Isolate creates a NetworkPolicy owned by the session pod which only admits
+// traffic from the hub and only lets traffic out to the allowlist. The pod
+// keeps its endpoint.
+func (k *KubeClient) Isolate(ctx context.Context, name string, policy blueclient.IsolationPolicy) (*blueclient.Endpoint, error) {
+	if k.debug {
+		log.Printf("DEBUG: Isolate: name, policy: %s, %+v", name, policy)
+	}
+
+	pod, err := k.getPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	np, err := buildNetworkPolicy(pod, policy)
+	if err != nil {
+		return nil, err
+	}
+	err = k.clientset.NetworkingV1().RESTClient().Post().
+		Namespace(k.namespace).
+		Resource("networkpolicies").
+		Body(np).
+		Context(ctx).
+		Timeout(k.timeouts.Create).
+		Do().
+		Error()
+	if err != nil {
+		return nil, err
+	}
+	return podEndpoint(pod), nil
+}
+
+// RemoveIsolation deletes the NetworkPolicy of the pod. Deleting the pod
+// does the same.
+func (k *KubeClient) RemoveIsolation(ctx context.Context, name string) error {
+	if k.debug {
+		log.Printf("DEBUG: RemoveIsolation: name: %s", name)
+	}
+
+	err := k.clientset.NetworkingV1().RESTClient().Delete().
+		Namespace(k.namespace).
+		Resource("networkpolicies").
+		Name(name).
+		Context(ctx).
+		Timeout(k.timeouts.Delete).
+		Do().
+		Error()
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func buildNetworkPolicy(pod *apiv1.Pod, policy blueclient.IsolationPolicy) (*networkingv1.NetworkPolicy, error) {
+	selector, err := sessionSelector(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod.Name,
+			Labels:          selector,
+			OwnerReferences: ownedByPod(pod),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: selector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			// empty rule lists deny everything
+			Ingress: []networkingv1.NetworkPolicyIngressRule{},
+			Egress:  []networkingv1.NetworkPolicyEgressRule{},
+		},
+	}
+
+	var from []networkingv1.NetworkPolicyPeer
+	for _, cidr := range policy.HubCIDRs {
+		from = append(from, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	if len(policy.HubSelector) > 0 {
+		from = append(from, networkingv1.NetworkPolicyPeer{
+			PodSelector: &metav1.LabelSelector{MatchLabels: policy.HubSelector},
+		})
+	}
+	if len(from) > 0 {
+		np.Spec.Ingress = append(np.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			From:  from,
+			Ports: policyPorts(apiv1.ProtocolTCP, policy.Ports...),
+		})
+	}
+
+	var to []networkingv1.NetworkPolicyPeer
+	for _, cidr := range policy.EgressCIDRs {
+		to = append(to, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	if len(to) > 0 {
+		np.Spec.Egress = append(np.Spec.Egress, networkingv1.NetworkPolicyEgressRule{To: to})
+	}
+	if policy.AllowDNS {
+		np.Spec.Egress = append(np.Spec.Egress, networkingv1.NetworkPolicyEgressRule{
+			Ports: append(policyPorts(apiv1.ProtocolUDP, dnsPort), policyPorts(apiv1.ProtocolTCP, dnsPort)...),
+		})
+	}
+	return np, nil
+}
+
+func policyPorts(protocol apiv1.Protocol, ports ...int) []networkingv1.NetworkPolicyPort {
+	var result []networkingv1.NetworkPolicyPort
+	for _, port := range ports {
+		protocol, port := protocol, intstr.FromInt(port)
+		result = append(result, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port})
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package kube
+
+import (
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+	blueclient "github.com/kolobok01/util/client"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+var _ blueclient.Isolator = &KubeClient{}
+
+func TestBuildNetworkPolicy(t *testing.T) {
+	pod, _ := BuildSessionPod("42", "blueio/images:chrome_70.0", nil)
+	pod.Name = "blueio-abc"
+	np, err := buildNetworkPolicy(pod, blueclient.IsolationPolicy{
+		HubSelector: map[string]string{"app": "hub"},
+		EgressCIDRs: []string{"10.10.0.0/16"},
+		AllowDNS:    true,
+		Ports:       []int{4444},
+	})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, np.Spec.PodSelector.MatchLabels[blueclient.RequestIDLabel], EqualTo{"42"})
+	AssertThat(t, np.OwnerReferences[0].Name, EqualTo{"blueio-abc"})
+	AssertThat(t, len(np.Spec.Ingress), EqualTo{1})
+	AssertThat(t, np.Spec.Ingress[0].From[0].PodSelector.MatchLabels, EqualTo{map[string]string{"app": "hub"}})
+	AssertThat(t, np.Spec.Ingress[0].Ports[0].Port.IntValue(), EqualTo{4444})
+	AssertThat(t, len(np.Spec.Egress), EqualTo{2})
+	AssertThat(t, np.Spec.Egress[0].To[0].IPBlock.CIDR, EqualTo{"10.10.0.0/16"})
+	AssertThat(t, len(np.Spec.Egress[1].Ports), EqualTo{2})
+}
+
+func TestBuildNetworkPolicyDenyAll(t *testing.T) {
+	pod, _ := BuildSessionPod("42", "blueio/images:chrome_70.0", nil)
+	np, err := buildNetworkPolicy(pod, blueclient.IsolationPolicy{})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, np.Spec.PolicyTypes, EqualTo{[]networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}})
+	AssertThat(t, len(np.Spec.Ingress), EqualTo{0})
+	AssertThat(t, len(np.Spec.Egress), EqualTo{0})
+}
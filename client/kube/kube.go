@@ -3,73 +3,137 @@ package kube
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
 	"sync"
+	"time"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/daemon/network"
 	blueclient "github.com/kolobok01/util/client"
 
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/rest"
 )
 
 const (
 	defaultImageTag               = "latest"
 	defaultNamespace              = apiv1.NamespaceDefault
-	defaultSeleniumSessionIDField = "Selenium"
+	defaultSeleniumSessionIDField = blueclient.SessionIDLabel
 	kubeApiVersion                = "KUBE_API_VERSION"
-)
-
-var (
-	kubeconfig string
+	kubeconfigEnv                 = "KUBECONFIG"
+	serviceHostEnv                = "KUBERNETES_SERVICE_HOST"
+	serviceAccountToken           = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 )
 
 type KubeClient struct {
 	Type       string
 	PodManager v1.PodInterface
-	debug      bool
-	mu         sync.Mutex
+	config     *rest.Config
+	clientset  kubernetes.Interface
+	namespace  string
+	timeouts   Timeouts
+	// image pull secrets of the pull pods, see PullImage
+	pullSecrets []string
+	debug       bool
+	mu          sync.Mutex
+
+	// session pod cache, see StartCache
+	pods *podStore
+
+	// stop channels of port-forwards by pod, see Expose
+	forwards map[string]chan struct{}
+}
+
+func init() {
+	blueclient.Register(blueclient.Backend{
+		Type:     blueclient.KubeType,
+		Priority: 10,
+		Detect:   detect,
+		New:      newFromOptions,
+	})
 }
 
 func CreateCompatibleClient(onVersionSpecified, onVersionDetermined, onUsingDefaultVersion func(string)) (*KubeClient, error) {
-	kubeconfig := filepath.Join(os.Getenv("HOME"), ".kube", "config")
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		return nil, err
+	return NewClient(context.Background(), Options{}, onVersionDetermined)
+}
+
+func detect(ctx context.Context) error {
+	if inCluster() {
+		return nil
 	}
+	if os.Getenv(kubeconfigEnv) != "" {
+		return nil
+	}
+	return fmt.Errorf("no in-cluster service account found and %s is not set", kubeconfigEnv)
+}
+
+func inCluster() bool {
+	if os.Getenv(serviceHostEnv) == "" {
+		return false
+	}
+	_, err := os.Stat(serviceAccountToken)
+	return err == nil
+}
 
+func newFromOptions(ctx context.Context, opts blueclient.Options) (blueclient.Client, error) {
+	return NewClient(ctx, Options{InCluster: inCluster()}, opts.OnVersion)
+}
+
+func newClient(ctx context.Context, config *rest.Config, namespace string, timeouts Timeouts, onVersionDetermined func(string)) (*KubeClient, error) {
 	cli, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
-	dcli := cli.DiscoveryClient
-	v, err := dcli.ServerVersion()
+	v, err := serverVersion(ctx, cli, timeouts.Get)
 	if err != nil {
 		return nil, err
 	} else {
 		onVersionDetermined(v.String())
 	}
 
-	podManager := cli.CoreV1().Pods(defaultNamespace)
+	podManager := cli.CoreV1().Pods(namespace)
 	return &KubeClient{
 		Type:       blueclient.KubeType,
 		PodManager: podManager,
+		config:     config,
+		clientset:  cli,
+		namespace:  namespace,
+		timeouts:   timeouts,
 	}, nil
 }
 
+// serverVersion is the ServerVersion of the discovery client, which takes
+// no context.
+func serverVersion(ctx context.Context, cli kubernetes.Interface, timeout time.Duration) (*version.Info, error) {
+	body, err := cli.Discovery().RESTClient().Get().
+		AbsPath("/version").
+		Context(ctx).
+		Timeout(timeout).
+		Do().
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("unable to parse the server version: %v", err)
+	}
+	return &info, nil
+}
+
+func (k *KubeClient) Namespace() string {
+	return k.namespace
+}
+
 func (k *KubeClient) GetType() string {
 	if k.debug {
 		log.Printf("DEBUG: type is %s", k.Type)
@@ -82,9 +146,11 @@ func (k *KubeClient) GetLogs(ctx context.Context, id string) (io.ReadCloser, err
 		log.Printf("DEBUG: Starting GetLogs for ID, context: %s, %+v", id, ctx)
 	}
 
-	req := k.PodManager.GetLogs(id, nil)
-	res := req.Do()
-	logs, err := res.Raw()
+	var logs []byte
+	container, err := k.browserContainer(ctx, id)
+	if err == nil {
+		logs, err = k.podLogs(ctx, id, &apiv1.PodLogOptions{Container: container})
+	}
 	if err != nil {
 		if k.debug {
 			log.Printf("DEBUG: error in GetLogs for ID %s: %s", id, err.Error())
@@ -105,12 +171,12 @@ func (k *KubeClient) SetDebug(debug bool) {
 	k.debug = debug
 }
 
-func (k *KubeClient) LaunchPod(name string, podSpec *apiv1.PodSpec) (*apiv1.Pod, error) {
+func (k *KubeClient) LaunchPod(ctx context.Context, name string, podSpec *apiv1.PodSpec) (*apiv1.Pod, error) {
 	if k.debug {
 		log.Printf("DEBUG: LaunchPod: PodSpec: %+v", podSpec)
 	}
 
-	return k.PodManager.Create(&apiv1.Pod{
+	return k.createPod(ctx, &apiv1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: name,
 		},
@@ -118,132 +184,82 @@ func (k *KubeClient) LaunchPod(name string, podSpec *apiv1.PodSpec) (*apiv1.Pod,
 	})
 }
 
-func BuildSessionPod(requestId, image string, video bool) (*apiv1.Pod, error) {
-	browser, version, err := getBrowserAndVersion(image)
-	if err != nil {
-		return nil, err
-	}
-	minor := (*version - float64(int(*version))) * 10
-	containers := []apiv1.Container{
-		{
-			Name:  fmt.Sprintf("req-%s-brow-%s-ver-%.0f-%.0f", requestId, *browser, *version, minor),
-			Image: fmt.Sprintf("%s", image),
-		},
-	}
-	return &apiv1.Pod{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "Pod",
-			APIVersion: apiv1.SchemeGroupVersion.String(),
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace:    defaultNamespace,
-			GenerateName: "blueio-",
-		},
-		Spec: apiv1.PodSpec{
-			RestartPolicy: apiv1.RestartPolicyNever,
-			Containers:    containers,
-		},
-	}, nil
-}
-
-func (k *KubeClient) CreateSessionPod(requestId, image string) (*apiv1.Pod, error) {
+func (k *KubeClient) CreateSessionPod(ctx context.Context, requestId, image string, opts *SessionOptions) (*apiv1.Pod, error) {
 	if k.debug {
 		log.Printf("DEBUG: CreateSessionPod: requestId, image: %s, %s", requestId, image)
 	}
 
-	pod, err := BuildSessionPod(requestId, image)
+	pod, err := BuildSessionPod(requestId, image, opts)
 	if err != nil {
 		if k.debug {
 			log.Printf("DEBUG: CreateSessionPod: got err %s", err)
 		}
 		return nil, err
 	}
-	return k.CreatePod(pod)
+	return k.CreatePod(ctx, pod)
 }
 
-func (k *KubeClient) CreatePod(pod *apiv1.Pod) (*apiv1.Pod, error) {
-	if k.debug {
-		log.Printf("DEBUG: CreatePod: podspec: %+v", pod)
+// StartSessionPod creates a session pod and returns once its WebDriver
+// answers the probe. A pod that does not get there is diagnosed and
+// deleted, the error is then a *SessionPodError.
+func (k *KubeClient) StartSessionPod(ctx context.Context, requestId, image string, opts *SessionOptions, probe blueclient.Probe) (*apiv1.Pod, *blueclient.Endpoint, error) {
+	pod, err := k.CreateSessionPod(ctx, requestId, image, opts)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	return k.PodManager.Create(pod)
+	ep, err := blueclient.WaitSession(ctx, k, pod.Name, sessionProbe(opts, probe))
+	if err != nil {
+		if k.debug {
+			log.Printf("DEBUG: StartSessionPod: pod %s failed to start: %s", pod.Name, err)
+		}
+		err = k.sessionPodError(context.Background(), pod.Name, err)
+		if deleteErr := k.DeletePodByName(context.Background(), pod.Name); deleteErr != nil {
+			log.Printf("WARNING: cannot delete failed session pod %s: %s", pod.Name, deleteErr)
+		}
+		return nil, nil, err
+	}
+	return pod, ep, nil
 }
 
-func (k *KubeClient) DeletePodByName(name string) error {
-	if k.debug {
-		log.Printf("DEBUG: DeletePodByName: name: %s", name)
+func (k *KubeClient) sessionPodError(ctx context.Context, name string, err error) error {
+	d, diagnoseErr := k.Diagnose(ctx, name)
+	if diagnoseErr != nil {
+		log.Printf("WARNING: cannot diagnose failed session pod %s: %s", name, diagnoseErr)
 	}
-
-	return k.PodManager.Delete(name, &metav1.DeleteOptions{
-		// TODO: get proper options
-	})
+	return &SessionPodError{Err: err, Diagnosis: d}
 }
 
-func (k *KubeClient) GetPodByName(name string) (*apiv1.Pod, error) {
-	if k.debug {
-		log.Printf("DEBUG: GetPodByName: name: %s", name)
+// sessionProbe points the probe at the WebDriver of the pod options.
+func sessionProbe(opts *SessionOptions, probe blueclient.Probe) blueclient.Probe {
+	if opts == nil {
+		return probe
 	}
-
-	return k.PodManager.Get(name, metav1.GetOptions{})
+	return opts.settings().Probe(probe)
 }
 
-func (k *KubeClient) GetPodBySessionID(name string) (*apiv1.Pod, error) {
+func (k *KubeClient) CreatePod(ctx context.Context, pod *apiv1.Pod) (*apiv1.Pod, error) {
 	if k.debug {
-		log.Printf("DEBUG: GetPodBySessionID: name: %s", name)
+		log.Printf("DEBUG: CreatePod: podspec: %+v", pod)
 	}
 
-	return k.PodManager.Get(name, metav1.GetOptions{})
+	return k.createPod(ctx, pod)
 }
 
-func (k *KubeClient) AddSessionID(name, sessionID string) error {
-	k.mu.Lock()
-	defer k.mu.Unlock()
-
-	pod, err := k.GetPodByName(name)
-	if err != nil {
-		return err
-	}
-	current := pod.Labels[defaultSeleniumSessionIDField]
-	if current != "" {
-		return fmt.Errorf("%s is already set on pod %s", defaultSeleniumSessionIDField, name)
-	}
-
-	if pod.Labels == nil {
-		pod.Labels = make(map[string]string)
+func (k *KubeClient) DeletePodByName(ctx context.Context, name string) error {
+	if k.debug {
+		log.Printf("DEBUG: DeletePodByName: name: %s", name)
 	}
-	pod.Labels[defaultSeleniumSessionIDField] = sessionID
-
-	_, err = k.PodManager.Update(pod)
-	return err
 
+	return k.DeletePod(ctx, name, DeleteOptions{})
 }
 
-func getBrowserAndVersion(image string) (*string, *float64, error) {
-	parts := strings.Split(image, ":")
-	if len(parts) != 2 {
-		return nil, nil, fmt.Errorf("incorrect input, should be in <image>:<version> format, got: %s", image)
-	}
-	browserAndVersion := parts[1]
-	browserAndVersionSlice := strings.Split(browserAndVersion, "_")
-	if len(parts) != 2 {
-		return nil, nil, fmt.Errorf("incorrect input, should be in <browser>_<version_number> format, got: %s", browserAndVersion)
-	}
-	browser := browserAndVersionSlice[0]
-	versionString := browserAndVersionSlice[1]
-	version, err := strconv.ParseFloat(versionString, 32)
-	if err == nil {
-		return &browser, &version, nil
+func (k *KubeClient) GetPodByName(ctx context.Context, name string) (*apiv1.Pod, error) {
+	if k.debug {
+		log.Printf("DEBUG: GetPodByName: name: %s", name)
 	}
-	return nil, nil, fmt.Errorf("got err on browserVersion parse: %s", err.Error())
-}
 
-// NetworkConnect connects a container to an existent network in the docker host.
-func (k *KubeClient) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
-	nc := types.NetworkConnect{
-		Container:      containerID,
-		EndpointConfig: config,
+	if pod := k.cachedPod(name); pod != nil {
+		return pod, nil
 	}
-	resp, err := cli.post(ctx, "/networks/"+networkID+"/connect", nil, nc, nil)
-	ensureReaderClosed(resp)
-	return err
+	return k.getPod(ctx, name)
 }
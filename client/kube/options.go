@@ -0,0 +1,87 @@
+package kube
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+type Options struct {
+	// InCluster uses the service account of the pod the client runs in.
+	InCluster bool
+	// Kubeconfig is a list of files separated like in KUBECONFIG which are
+	// merged in order. It defaults to KUBECONFIG and then to $HOME/.kube/config.
+	Kubeconfig string
+	// Context overrides the current context of the kubeconfig.
+	Context string
+	// Namespace defaults to the namespace of the context or of the service
+	// account and then to "default".
+	Namespace string
+	QPS       float32
+	Burst     int
+	UserAgent string
+	Timeouts  Timeouts
+	// ImagePullSecrets name the secrets PullImage pulls private images with.
+	ImagePullSecrets []string
+}
+
+func NewClient(ctx context.Context, opts Options, onVersionDetermined func(string)) (*KubeClient, error) {
+	config, namespace, err := opts.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Namespace != "" {
+		namespace = opts.Namespace
+	}
+	if opts.QPS > 0 {
+		config.QPS = opts.QPS
+	}
+	if opts.Burst > 0 {
+		config.Burst = opts.Burst
+	}
+	if opts.UserAgent != "" {
+		config.UserAgent = opts.UserAgent
+	}
+	k, err := newClient(ctx, config, namespace, opts.Timeouts.withDefaults(), onVersionDetermined)
+	if err != nil {
+		return nil, err
+	}
+	k.pullSecrets = opts.ImagePullSecrets
+	return k, nil
+}
+
+func (opts Options) restConfig() (*rest.Config, string, error) {
+	if opts.InCluster {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, "", err
+		}
+		namespace := defaultNamespace
+		if data, err := ioutil.ReadFile(serviceAccountNamespace); err == nil && len(data) > 0 {
+			namespace = strings.TrimSpace(string(data))
+		}
+		return config, namespace, nil
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if opts.Kubeconfig != "" {
+		rules.Precedence = filepath.SplitList(opts.Kubeconfig)
+	}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: opts.Context}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, "", err
+	}
+	return config, namespace, nil
+}
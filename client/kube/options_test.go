@@ -0,0 +1,80 @@
+package kube
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+)
+
+const clustersConfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+- name: prod
+  cluster:
+    server: https://prod.example.com
+users:
+- name: robot
+  user:
+    token: secret
+`
+
+const contextsConfig = `
+apiVersion: v1
+kind: Config
+current-context: dev
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: robot
+    namespace: team-dev
+- name: prod
+  context:
+    cluster: prod
+    user: robot
+`
+
+func writeConfigs(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	AssertThat(t, err, Is{nil})
+	clusters := filepath.Join(dir, "clusters")
+	contexts := filepath.Join(dir, "contexts")
+	AssertThat(t, ioutil.WriteFile(clusters, []byte(clustersConfig), 0600), Is{nil})
+	AssertThat(t, ioutil.WriteFile(contexts, []byte(contextsConfig), 0600), Is{nil})
+	return clusters + string(filepath.ListSeparator) + contexts, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRestConfigMergesKubeconfigs(t *testing.T) {
+	kubeconfig, cleanup := writeConfigs(t)
+	defer cleanup()
+	config, namespace, err := Options{Kubeconfig: kubeconfig}.restConfig()
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, config.Host, EqualTo{"https://dev.example.com"})
+	AssertThat(t, config.BearerToken, EqualTo{"secret"})
+	AssertThat(t, namespace, EqualTo{"team-dev"})
+}
+
+func TestRestConfigExplicitContext(t *testing.T) {
+	kubeconfig, cleanup := writeConfigs(t)
+	defer cleanup()
+	config, namespace, err := Options{Kubeconfig: kubeconfig, Context: "prod"}.restConfig()
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, config.Host, EqualTo{"https://prod.example.com"})
+	AssertThat(t, namespace, EqualTo{"default"})
+}
+
+func TestRestConfigUnknownContext(t *testing.T) {
+	kubeconfig, cleanup := writeConfigs(t)
+	defer cleanup()
+	_, _, err := Options{Kubeconfig: kubeconfig, Context: "missing"}.restConfig()
+	AssertThat(t, err, Not{nil})
+}
@@ -0,0 +1,130 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	maxConflictRetries   = 5
+	conflictRetryBackoff = 50 * time.Millisecond
+)
+
+// metadataPatch is a strategic merge patch of pod metadata. A non empty
+// ResourceVersion makes the API server reject the patch with 409 Conflict
+// when the pod was changed since it was read.
+type metadataPatch struct {
+	Metadata struct {
+		ResourceVersion string             `json:"resourceVersion,omitempty"`
+		Labels          map[string]*string `json:"labels,omitempty"`
+		Annotations     map[string]*string `json:"annotations,omitempty"`
+	} `json:"metadata"`
+}
+
+// SetLabels adds or replaces labels of the pod, labels of other writers are
+// left untouched. An empty value removes the label.
+func (k *KubeClient) SetLabels(ctx context.Context, name string, labels map[string]string) error {
+	if k.debug {
+		log.Printf("DEBUG: SetLabels: name, labels: %s, %v", name, labels)
+	}
+
+	var patch metadataPatch
+	patch.Metadata.Labels = patchValues(labels)
+	return k.patchMetadata(ctx, name, &patch)
+}
+
+// SetAnnotations is SetLabels for annotations.
+func (k *KubeClient) SetAnnotations(ctx context.Context, name string, annotations map[string]string) error {
+	if k.debug {
+		log.Printf("DEBUG: SetAnnotations: name, annotations: %s, %v", name, annotations)
+	}
+
+	var patch metadataPatch
+	patch.Metadata.Annotations = patchValues(annotations)
+	return k.patchMetadata(ctx, name, &patch)
+}
+
+// SetLabelIfEmpty sets the label only if the pod has no value for it yet.
+// Setting the value the label already has succeeds, so the call can be
+// retried; any other value already set is an error.
+func (k *KubeClient) SetLabelIfEmpty(ctx context.Context, name, key, value string) error {
+	if k.debug {
+		log.Printf("DEBUG: SetLabelIfEmpty: name, key, value: %s, %s, %s", name, key, value)
+	}
+
+	return k.retryOnConflict(ctx, func() error {
+		pod, err := k.getPod(ctx, name)
+		if err != nil {
+			return err
+		}
+		switch current := pod.Labels[key]; current {
+		case value:
+			return nil
+		case "":
+		default:
+			return fmt.Errorf("%s is already set to %s on pod %s", key, current, name)
+		}
+		var patch metadataPatch
+		patch.Metadata.ResourceVersion = pod.ResourceVersion
+		patch.Metadata.Labels = patchValues(map[string]string{key: value})
+		return k.patchMetadata(ctx, name, &patch)
+	})
+}
+
+// AddSessionID labels the pod with the Selenium session ID it serves, a pod
+// can only serve one session.
+func (k *KubeClient) AddSessionID(ctx context.Context, name, sessionID string) error {
+	return k.SetLabelIfEmpty(ctx, name, defaultSeleniumSessionIDField, sessionID)
+}
+
+func (k *KubeClient) retryOnConflict(ctx context.Context, fn func() error) error {
+	backoff := conflictRetryBackoff
+	for i := 0; ; i++ {
+		err := fn()
+		if !apierrors.IsConflict(err) || i == maxConflictRetries {
+			return err
+		}
+		if k.debug {
+			log.Printf("DEBUG: retryOnConflict: attempt %d: %s", i+1, err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (k *KubeClient) patchMetadata(ctx context.Context, name string, patch *metadataPatch) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = k.patchPod(ctx, name, types.StrategicMergePatchType, data)
+	return err
+}
+
+// patchValues turns empty values into nulls, which delete the key in a merge
+// patch.
+func patchValues(values map[string]string) map[string]*string {
+	if len(values) == 0 {
+		return nil
+	}
+	result := make(map[string]*string, len(values))
+	for k, v := range values {
+		if v == "" {
+			result[k] = nil
+			continue
+		}
+		v := v
+		result[k] = &v
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestMetadataPatch(t *testing.T) {
+	var patch metadataPatch
+	patch.Metadata.ResourceVersion = "42"
+	patch.Metadata.Labels = patchValues(map[string]string{"Selenium": "abc", "stale": ""})
+	data, err := json.Marshal(patch)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, string(data), EqualTo{`{"metadata":{"resourceVersion":"42","labels":{"Selenium":"abc","stale":null}}}`})
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	k := &KubeClient{}
+	conflict := apierrors.NewConflict(apiv1.Resource("pods"), "blueio-abc", errors.New("changed"))
+	attempts := 0
+	err := k.retryOnConflict(context.Background(), func() error {
+		attempts++
+		if attempts < 3 {
+			return conflict
+		}
+		return nil
+	})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, attempts, EqualTo{3})
+}
+
+func TestRetryOnConflictOtherError(t *testing.T) {
+	k := &KubeClient{}
+	attempts := 0
+	err := k.retryOnConflict(context.Background(), func() error {
+		attempts++
+		return errors.New("forbidden")
+	})
+	AssertThat(t, err, Not{nil})
+	AssertThat(t, attempts, EqualTo{1})
+}
@@ -0,0 +1,269 @@
+package kube
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	blueclient "github.com/kolobok01/util/client"
+	"github.com/kolobok01/util/client/capabilities"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	vncPort          = 5900
+	displayNumber    = 99
+	shmVolume        = "dshm"
+	x11Volume        = "x11"
+	videoVolume      = "video"
+	videoContainer   = "video"
+	defaultVideoPath = "/data"
+	x11SocketDir     = "/tmp/.X11-unix"
+)
+
+// SessionOptions configure the pod built by BuildSessionPod. The zero value
+// gives a single browser container exposing the WebDriver port.
+type SessionOptions struct {
+	User        string
+	Labels      map[string]string
+	Annotations map[string]string
+
+	// Browser and Version label the session, they are parsed from the
+	// image when empty.
+	Browser string
+	Version string
+
+	// Port is the WebDriver port of the image, 4444 by default, and Path
+	// the base path of the WebDriver, / by default.
+	Port             int32
+	Path             string
+	Env              map[string]string
+	ScreenResolution string
+	TimeZone         string
+	EnableVNC        bool
+
+	Resources apiv1.ResourceRequirements
+	// ShmSize mounts a memory backed /dev/shm of that size, browsers crash
+	// with the 64Mi container default.
+	ShmSize         *resource.Quantity
+	SecurityContext *apiv1.SecurityContext
+
+	NodeSelector     map[string]string
+	Tolerations      []apiv1.Toleration
+	Affinity         *apiv1.Affinity
+	ImagePullSecrets []string
+
+	Video *VideoOptions
+}
+
+// VideoOptions add a recorder sidecar grabbing the X display of the browser.
+type VideoOptions struct {
+	Image     string
+	FileName  string
+	FrameRate int
+	Resources apiv1.ResourceRequirements
+	// Volume receives the recording, an emptyDir by default.
+	Volume *apiv1.VolumeSource
+}
+
+func BuildSessionPod(requestId, image string, opts *SessionOptions) (*apiv1.Pod, error) {
+	if opts == nil {
+		opts = &SessionOptions{}
+	}
+	browser, version, err := blueclient.SessionBrowser(image, opts.Browser, opts.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	port := opts.Port
+	if port == 0 {
+		port = blueclient.DefaultWebDriverPort
+	}
+	container := apiv1.Container{
+		Name:            containerName(requestId, browser, version),
+		Image:           image,
+		Ports:           []apiv1.ContainerPort{{Name: "webdriver", ContainerPort: port}},
+		Env:             envVars(opts.settings().Environment()),
+		Resources:       opts.Resources,
+		SecurityContext: opts.SecurityContext,
+	}
+	if opts.EnableVNC {
+		container.Ports = append(container.Ports, apiv1.ContainerPort{Name: "vnc", ContainerPort: vncPort})
+	}
+
+	automount := false
+	spec := apiv1.PodSpec{
+		RestartPolicy:                apiv1.RestartPolicyNever,
+		NodeSelector:                 opts.NodeSelector,
+		Tolerations:                  opts.Tolerations,
+		Affinity:                     opts.Affinity,
+		AutomountServiceAccountToken: &automount,
+	}
+	for _, secret := range opts.ImagePullSecrets {
+		spec.ImagePullSecrets = append(spec.ImagePullSecrets, apiv1.LocalObjectReference{Name: secret})
+	}
+	if opts.ShmSize != nil {
+		spec.Volumes = append(spec.Volumes, apiv1.Volume{
+			Name: shmVolume,
+			VolumeSource: apiv1.VolumeSource{
+				EmptyDir: &apiv1.EmptyDirVolumeSource{Medium: apiv1.StorageMediumMemory, SizeLimit: opts.ShmSize},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, apiv1.VolumeMount{Name: shmVolume, MountPath: "/dev/shm"})
+	}
+	containers := []apiv1.Container{container}
+	if opts.Video != nil {
+		containers = withVideo(&spec, containers, requestId, opts)
+	}
+	spec.Containers = containers
+
+	labels := map[string]string{
+		blueclient.ManagedLabel:   blueclient.ManagedValue,
+		blueclient.RequestIDLabel: requestId,
+		blueclient.BrowserLabel:   browser,
+		blueclient.VersionLabel:   version,
+	}
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+	annotations := make(map[string]string)
+	if opts.User != "" {
+		annotations[blueclient.UserAnnotation] = opts.User
+	}
+	for k, v := range opts.Annotations {
+		annotations[k] = v
+	}
+
+	return &apiv1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: apiv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "blueio-",
+			Labels:       labels,
+			Annotations:  annotations,
+		},
+		Spec: spec,
+	}, nil
+}
+
+// maxContainerName is the length limit of DNS labels.
+const maxContainerName = 63
+
+var invalidContainerName = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// containerName makes a DNS label of the request, browser and version,
+// versions like 120.0.6099_beta are not valid in container names as is.
+func containerName(requestId, browser, version string) string {
+	name := strings.ToLower(fmt.Sprintf("req-%s-brow-%s-ver-%s", requestId, browser, version))
+	name = invalidContainerName.ReplaceAllString(name, "-")
+	if len(name) > maxContainerName {
+		name = name[:maxContainerName]
+	}
+	return strings.Trim(name, "-")
+}
+
+// MatchedSessionOptions returns the options of a session for the
+// capabilities matched in the catalog, the image is m.Browser.Image. Sessions
+// asking for a video get a copy of the video options, which sets the image
+// of the recorder.
+func MatchedSessionOptions(m *capabilities.Match, video *VideoOptions) *SessionOptions {
+	caps := m.Capabilities.Options
+	settings := m.Settings()
+	opts := &SessionOptions{
+		Labels:           settings.Labels,
+		Browser:          settings.Browser,
+		Version:          settings.Version,
+		Port:             int32(settings.Port),
+		Path:             settings.Path,
+		Env:              settings.Env,
+		ScreenResolution: settings.ScreenResolution,
+		TimeZone:         settings.TimeZone,
+		EnableVNC:        settings.EnableVNC,
+	}
+	if caps.EnableVideo && video != nil {
+		v := *video
+		if caps.VideoName != "" {
+			v.FileName = caps.VideoName
+		}
+		opts.Video = &v
+	}
+	return opts
+}
+
+func (opts *SessionOptions) settings() blueclient.SessionSettings {
+	return blueclient.SessionSettings{
+		Labels:           opts.Labels,
+		Browser:          opts.Browser,
+		Version:          opts.Version,
+		Port:             int(opts.Port),
+		Path:             opts.Path,
+		Env:              opts.Env,
+		ScreenResolution: opts.ScreenResolution,
+		TimeZone:         opts.TimeZone,
+		EnableVNC:        opts.EnableVNC,
+	}
+}
+
+// withVideo shares the X11 socket directory of the browser with a recorder
+// sidecar, both containers also share the network namespace of the pod.
+func withVideo(spec *apiv1.PodSpec, containers []apiv1.Container, requestId string, opts *SessionOptions) []apiv1.Container {
+	video := opts.Video
+	spec.Volumes = append(spec.Volumes, apiv1.Volume{
+		Name:         x11Volume,
+		VolumeSource: apiv1.VolumeSource{EmptyDir: &apiv1.EmptyDirVolumeSource{}},
+	})
+	output := apiv1.VolumeSource{EmptyDir: &apiv1.EmptyDirVolumeSource{}}
+	if video.Volume != nil {
+		output = *video.Volume
+	}
+	spec.Volumes = append(spec.Volumes, apiv1.Volume{Name: videoVolume, VolumeSource: output})
+
+	x11Mount := apiv1.VolumeMount{Name: x11Volume, MountPath: x11SocketDir}
+	containers[0].VolumeMounts = append(containers[0].VolumeMounts, x11Mount)
+
+	fileName := video.FileName
+	if fileName == "" {
+		fileName = requestId + ".mp4"
+	}
+	env := map[string]string{
+		"BROWSER_CONTAINER_NAME": "localhost",
+		"DISPLAY":                fmt.Sprintf(":%d", displayNumber),
+		"FILE_NAME":              fileName,
+	}
+	if opts.ScreenResolution != "" {
+		env["VIDEO_SIZE"] = opts.ScreenResolution
+	}
+	if video.FrameRate > 0 {
+		env["FRAME_RATE"] = strconv.Itoa(video.FrameRate)
+	}
+	return append(containers, apiv1.Container{
+		Name:      videoContainer,
+		Image:     video.Image,
+		Env:       envVars(env),
+		Resources: video.Resources,
+		VolumeMounts: []apiv1.VolumeMount{
+			x11Mount,
+			{Name: videoVolume, MountPath: defaultVideoPath},
+		},
+	})
+}
+
+func envVars(env map[string]string) []apiv1.EnvVar {
+	var names []string
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var vars []apiv1.EnvVar
+	for _, name := range names {
+		vars = append(vars, apiv1.EnvVar{Name: name, Value: env[name]})
+	}
+	return vars
+}
@@ -0,0 +1,132 @@
+package kube
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+	blueclient "github.com/kolobok01/util/client"
+	"github.com/kolobok01/util/client/capabilities"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestBuildSessionPodDefaults(t *testing.T) {
+	pod, err := BuildSessionPod("42", "blueio/images:chrome_70.1", nil)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, pod.GenerateName, EqualTo{"blueio-"})
+	AssertThat(t, pod.Labels, EqualTo{map[string]string{
+		blueclient.ManagedLabel:   blueclient.ManagedValue,
+		blueclient.RequestIDLabel: "42",
+		blueclient.BrowserLabel:   "chrome",
+		blueclient.VersionLabel:   "70.1",
+	}})
+	AssertThat(t, len(pod.Spec.Containers), EqualTo{1})
+	container := pod.Spec.Containers[0]
+	AssertThat(t, container.Name, EqualTo{"req-42-brow-chrome-ver-70-1"})
+	AssertThat(t, container.Image, EqualTo{"blueio/images:chrome_70.1"})
+	AssertThat(t, container.Ports, EqualTo{[]apiv1.ContainerPort{{Name: "webdriver", ContainerPort: 4444}}})
+	AssertThat(t, len(container.Env), EqualTo{0})
+	AssertThat(t, len(pod.Spec.Volumes), EqualTo{0})
+}
+
+func TestBuildSessionPodOptions(t *testing.T) {
+	shm := resource.MustParse("1Gi")
+	pod, err := BuildSessionPod("42", "blueio/images:firefox_63.0", &SessionOptions{
+		User:             "alice",
+		Labels:           map[string]string{"team": "qa"},
+		Env:              map[string]string{"LANG": "en_US.UTF-8"},
+		ScreenResolution: "1920x1080x24",
+		TimeZone:         "Europe/Moscow",
+		EnableVNC:        true,
+		ShmSize:          &shm,
+		NodeSelector:     map[string]string{"pool": "browsers"},
+		ImagePullSecrets: []string{"registry"},
+	})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, pod.Labels["team"], EqualTo{"qa"})
+	AssertThat(t, pod.Annotations, EqualTo{map[string]string{blueclient.UserAnnotation: "alice"}})
+	AssertThat(t, pod.Spec.NodeSelector, EqualTo{map[string]string{"pool": "browsers"}})
+	AssertThat(t, pod.Spec.ImagePullSecrets, EqualTo{[]apiv1.LocalObjectReference{{Name: "registry"}}})
+
+	container := pod.Spec.Containers[0]
+	AssertThat(t, container.Env, EqualTo{[]apiv1.EnvVar{
+		{Name: "ENABLE_VNC", Value: "true"},
+		{Name: "LANG", Value: "en_US.UTF-8"},
+		{Name: "SCREEN_RESOLUTION", Value: "1920x1080x24"},
+		{Name: "TZ", Value: "Europe/Moscow"},
+	}})
+	AssertThat(t, len(container.Ports), EqualTo{2})
+	AssertThat(t, container.VolumeMounts, EqualTo{[]apiv1.VolumeMount{{Name: shmVolume, MountPath: "/dev/shm"}}})
+	AssertThat(t, pod.Spec.Volumes[0].EmptyDir.Medium, EqualTo{apiv1.StorageMediumMemory})
+}
+
+func TestBuildSessionPodVideo(t *testing.T) {
+	pod, err := BuildSessionPod("42", "blueio/images:chrome_70.1", &SessionOptions{
+		ScreenResolution: "1280x1024x24",
+		Video:            &VideoOptions{Image: "selenoid/video-recorder"},
+	})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, len(pod.Spec.Containers), EqualTo{2})
+	browser, video := pod.Spec.Containers[0], pod.Spec.Containers[1]
+	AssertThat(t, video.Image, EqualTo{"selenoid/video-recorder"})
+	AssertThat(t, browser.VolumeMounts[0], EqualTo{video.VolumeMounts[0]})
+	AssertThat(t, video.Env, EqualTo{[]apiv1.EnvVar{
+		{Name: "BROWSER_CONTAINER_NAME", Value: "localhost"},
+		{Name: "DISPLAY", Value: ":99"},
+		{Name: "FILE_NAME", Value: "42.mp4"},
+		{Name: "VIDEO_SIZE", Value: "1280x1024x24"},
+	}})
+}
+
+func TestContainerName(t *testing.T) {
+	AssertThat(t, containerName("42", "chrome", "70.1"), EqualTo{"req-42-brow-chrome-ver-70-1"})
+	AssertThat(t, containerName("ABC", "MicrosoftEdge", "120.0.6099_beta"), EqualTo{"req-abc-brow-microsoftedge-ver-120-0-6099-beta"})
+	AssertThat(t, containerName("42", "chrome", "70.0."), EqualTo{"req-42-brow-chrome-ver-70-0"})
+
+	name := containerName(strings.Repeat("a", 60), "chrome", "70.0")
+	AssertThat(t, len(name), EqualTo{maxContainerName})
+	name = containerName(strings.Repeat("a", 58), "chrome", "70.0")
+	AssertThat(t, name, EqualTo{"req-" + strings.Repeat("a", 58)})
+}
+
+func TestBuildSessionPodBadImage(t *testing.T) {
+	_, err := BuildSessionPod("42", "chrome", nil)
+	AssertThat(t, err, Not{nil})
+}
+
+func TestMatchedSessionOptions(t *testing.T) {
+	m := &capabilities.Match{
+		Capabilities: capabilities.Capabilities{Options: capabilities.Options{EnableVideo: true, VideoName: "test.mp4"}},
+		Browser: &blueclient.ResolvedBrowser{Browser: "chrome", Version: "70.0", BrowserVersion: &blueclient.BrowserVersion{
+			Image: "selenoid/chrome:70.0",
+			Path:  "/wd/hub",
+		}},
+	}
+	video := &VideoOptions{Image: "blueio/video-recorder", FileName: "video.mp4"}
+	opts := MatchedSessionOptions(m, video)
+	AssertThat(t, opts.Port, EqualTo{int32(blueclient.DefaultWebDriverPort)})
+	AssertThat(t, opts.Video.Image, EqualTo{"blueio/video-recorder"})
+	AssertThat(t, opts.Video.FileName, EqualTo{"test.mp4"})
+	AssertThat(t, video.FileName, EqualTo{"video.mp4"})
+	AssertThat(t, sessionProbe(opts, blueclient.Probe{}).Path, EqualTo{"/wd/hub/status"})
+	pod, err := BuildSessionPod("42", m.Browser.Image, opts)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, pod.Labels[blueclient.VersionLabel], EqualTo{"70.0"})
+
+	m.Capabilities.Options.EnableVideo = false
+	AssertThat(t, MatchedSessionOptions(m, video).Video == nil, Is{true})
+}
+
+func TestSessionProbe(t *testing.T) {
+	probe := sessionProbe(&SessionOptions{Port: 5555}, blueclient.Probe{})
+	AssertThat(t, probe.Port, EqualTo{5555})
+
+	probe = sessionProbe(&SessionOptions{Port: 5555, Path: "/wd/hub/"}, blueclient.Probe{Port: 4444, Path: "/ready"})
+	AssertThat(t, probe.Port, EqualTo{4444})
+	AssertThat(t, probe.Path, EqualTo{"/ready"})
+
+	probe = sessionProbe(nil, blueclient.Probe{})
+	AssertThat(t, probe.Port, EqualTo{0})
+}
@@ -0,0 +1,165 @@
+package kube
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// Port-forward streams carry the data of a pod port on the first channel
+// and its errors on the second one. The first message of each channel is
+// the port, two bytes little endian.
+const (
+	portDataChannel = iota
+	portErrorChannel
+)
+
+// forwarder forwards the connections accepted on local ports to the ports
+// of a pod, each connection over a stream of its own.
+type forwarder struct {
+	k   *KubeClient
+	pod string
+	// local listeners by pod port
+	listeners map[int]net.Listener
+}
+
+func (k *KubeClient) newForwarder(pod string, ports []int) (*forwarder, error) {
+	fw := &forwarder{k: k, pod: pod, listeners: make(map[int]net.Listener)}
+	for _, port := range ports {
+		l, err := net.Listen("tcp", net.JoinHostPort(portForwardAddress, "0"))
+		if err != nil {
+			fw.close()
+			return nil, err
+		}
+		fw.listeners[port] = l
+	}
+	return fw, nil
+}
+
+func (fw *forwarder) url(port int) *url.URL {
+	return fw.k.restClient().Get().
+		Namespace(fw.k.namespace).
+		Resource("pods").
+		Name(fw.pod).
+		SubResource("portforward").
+		Param("ports", strconv.Itoa(port)).
+		URL()
+}
+
+// localPorts maps the pod ports to the local ports they are reachable on.
+func (fw *forwarder) localPorts() map[int]int {
+	ports := make(map[int]int)
+	for port, l := range fw.listeners {
+		ports[port] = l.Addr().(*net.TCPAddr).Port
+	}
+	return ports
+}
+
+// check opens a stream to the first port, which fails when the pod can not
+// be forwarded to.
+func (fw *forwarder) check(ctx context.Context) error {
+	for port := range fw.listeners {
+		conn, err := fw.k.dialStream(ctx, fw.url(port))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	return nil
+}
+
+func (fw *forwarder) close() {
+	for _, l := range fw.listeners {
+		l.Close()
+	}
+}
+
+// serve forwards the accepted connections until stop is closed.
+func (fw *forwarder) serve(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+		case <-ctx.Done():
+		}
+		fw.close()
+	}()
+
+	var wg sync.WaitGroup
+	for port, l := range fw.listeners {
+		wg.Add(1)
+		go func(port int, l net.Listener) {
+			defer wg.Done()
+			defer cancel()
+			for {
+				local, err := l.Accept()
+				if err != nil {
+					return
+				}
+				go fw.forward(ctx, port, local)
+			}
+		}(port, l)
+	}
+	wg.Wait()
+}
+
+// forward copies the connection to the port of the pod and back until
+// either side closes it.
+func (fw *forwarder) forward(ctx context.Context, port int, local net.Conn) {
+	defer local.Close()
+	conn, err := fw.k.dialStream(ctx, fw.url(port))
+	if err != nil {
+		log.Printf("WARNING: cannot forward port %d of %s: %v", port, fw.pod, err)
+		return
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+		local.Close()
+	}()
+	go func() {
+		writeChannel(conn, portDataChannel, local, done)
+		conn.Close()
+	}()
+
+	started := make(map[byte]bool)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if len(data) == 0 {
+			continue
+		}
+		channel, data := data[0], data[1:]
+		if !started[channel] {
+			started[channel] = true
+			if len(data) < 2 {
+				continue
+			}
+			data = data[2:]
+		}
+		if len(data) == 0 {
+			continue
+		}
+		switch channel {
+		case portDataChannel:
+			if _, err := local.Write(data); err != nil {
+				return
+			}
+		case portErrorChannel:
+			log.Printf("WARNING: port %d of %s: %s", port, fw.pod, data)
+		}
+	}
+}
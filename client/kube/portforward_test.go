@@ -0,0 +1,93 @@
+package kube
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+	"github.com/gorilla/websocket"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// echoServer answers the port-forwards to the session pod with the data it
+// receives.
+func echoServer() *httptest.Server {
+	upgrader := websocket.Upgrader{Subprotocols: []string{channelProtocol}}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/default/pods/session/portforward" {
+			http.NotFound(w, r)
+			return
+		}
+		port, _ := strconv.Atoi(r.URL.Query().Get("ports"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for _, channel := range []byte{portDataChannel, portErrorChannel} {
+			msg := []byte{channel, 0, 0}
+			binary.LittleEndian.PutUint16(msg[1:], uint16(port))
+			conn.WriteMessage(websocket.BinaryMessage, msg)
+		}
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(websocket.BinaryMessage, data)
+		}
+	}))
+}
+
+func TestPortForward(t *testing.T) {
+	srv := echoServer()
+	defer srv.Close()
+	k := execClient(t, srv.URL)
+
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "session"}}
+	ep, err := k.portForward(context.Background(), pod, []int{4444})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, ep.IP, EqualTo{portForwardAddress})
+
+	local, err := net.Dial("tcp", ep.Address(4444))
+	AssertThat(t, err, Is{nil})
+	defer local.Close()
+	local.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	_, err = local.Read(buf)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, string(buf), EqualTo{"ping"})
+
+	k.stopPortForward("session")
+}
+
+func TestPortForwardToMissingPod(t *testing.T) {
+	srv := echoServer()
+	defer srv.Close()
+	k := execClient(t, srv.URL)
+
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "missing"}}
+	_, err := k.portForward(context.Background(), pod, []int{4444})
+	AssertThat(t, err, Not{nil})
+	AssertThat(t, len(k.forwards), EqualTo{0})
+}
+
+func TestPortForwardCancelled(t *testing.T) {
+	srv := echoServer()
+	defer srv.Close()
+	k := execClient(t, srv.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "session"}}
+	_, err := k.portForward(ctx, pod, []int{4444})
+	AssertThat(t, err, Is{context.Canceled})
+	AssertThat(t, len(k.forwards), EqualTo{0})
+}
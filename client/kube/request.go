@@ -0,0 +1,127 @@
+package kube
+
+import (
+	"context"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// Timeouts bound the API requests of every KubeClient operation on top of the
+// context passed by the caller. Zero fields keep their default.
+type Timeouts struct {
+	Get    time.Duration
+	List   time.Duration
+	Create time.Duration
+	Patch  time.Duration
+	Delete time.Duration
+	Logs   time.Duration
+	// Pull bounds a whole PullImage, the pull pods bypass the scheduler and
+	// may never start on a node that goes away.
+	Pull time.Duration
+}
+
+var defaultTimeouts = Timeouts{
+	Get:    10 * time.Second,
+	List:   30 * time.Second,
+	Create: 30 * time.Second,
+	Patch:  10 * time.Second,
+	Delete: 30 * time.Second,
+	Logs:   time.Minute,
+	Pull:   10 * time.Minute,
+}
+
+func (t Timeouts) withDefaults() Timeouts {
+	set := func(d *time.Duration, def time.Duration) {
+		if *d <= 0 {
+			*d = def
+		}
+	}
+	set(&t.Get, defaultTimeouts.Get)
+	set(&t.List, defaultTimeouts.List)
+	set(&t.Create, defaultTimeouts.Create)
+	set(&t.Patch, defaultTimeouts.Patch)
+	set(&t.Delete, defaultTimeouts.Delete)
+	set(&t.Logs, defaultTimeouts.Logs)
+	set(&t.Pull, defaultTimeouts.Pull)
+	return t
+}
+
+// podRequest starts a request on the pods of the namespace. The request is
+// cancelled with ctx, its timeout only applies to non streaming requests.
+func (k *KubeClient) podRequest(ctx context.Context, req *rest.Request, timeout time.Duration) *rest.Request {
+	return req.Namespace(k.namespace).Resource("pods").Context(ctx).Timeout(timeout)
+}
+
+func (k *KubeClient) restClient() rest.Interface {
+	return k.clientset.CoreV1().RESTClient()
+}
+
+func (k *KubeClient) getPod(ctx context.Context, name string) (*apiv1.Pod, error) {
+	pod := &apiv1.Pod{}
+	err := k.podRequest(ctx, k.restClient().Get(), k.timeouts.Get).
+		Name(name).
+		VersionedParams(&metav1.GetOptions{}, scheme.ParameterCodec).
+		Do().
+		Into(pod)
+	return pod, err
+}
+
+func (k *KubeClient) listPods(ctx context.Context, opts metav1.ListOptions) (*apiv1.PodList, error) {
+	list := &apiv1.PodList{}
+	err := k.podRequest(ctx, k.restClient().Get(), k.timeouts.List).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(list)
+	return list, err
+}
+
+func (k *KubeClient) watchPods(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return k.restClient().Get().
+		Namespace(k.namespace).
+		Resource("pods").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Context(ctx).
+		Watch()
+}
+
+func (k *KubeClient) createPod(ctx context.Context, pod *apiv1.Pod) (*apiv1.Pod, error) {
+	result := &apiv1.Pod{}
+	err := k.podRequest(ctx, k.restClient().Post(), k.timeouts.Create).
+		Body(pod).
+		Do().
+		Into(result)
+	return result, err
+}
+
+func (k *KubeClient) deletePod(ctx context.Context, name string, opts *metav1.DeleteOptions) error {
+	return k.podRequest(ctx, k.restClient().Delete(), k.timeouts.Delete).
+		Name(name).
+		Body(opts).
+		Do().
+		Error()
+}
+
+func (k *KubeClient) patchPod(ctx context.Context, name string, patchType types.PatchType, data []byte) (*apiv1.Pod, error) {
+	pod := &apiv1.Pod{}
+	err := k.podRequest(ctx, k.restClient().Patch(patchType), k.timeouts.Patch).
+		Name(name).
+		Body(data).
+		Do().
+		Into(pod)
+	return pod, err
+}
+
+func (k *KubeClient) podLogs(ctx context.Context, name string, opts *apiv1.PodLogOptions) ([]byte, error) {
+	return k.podRequest(ctx, k.restClient().Get(), k.timeouts.Logs).
+		Name(name).
+		SubResource("log").
+		VersionedParams(opts, scheme.ParameterCodec).
+		DoRaw()
+}
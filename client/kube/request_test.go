@@ -0,0 +1,110 @@
+package kube
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+
+	"k8s.io/client-go/rest"
+)
+
+func apiServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"major": "1", "minor": "15", "gitVersion": "v1.15.0"}`))
+	})
+	mux.HandleFunc("/api/v1/namespaces/default/pods/hung", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/api/v1/namespaces/default/pods/session", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "session"}, "spec": {"containers": [{"name": "browser"}, {"name": "video"}]}}`))
+	})
+	mux.HandleFunc("/api/v1/namespaces/default/pods/session/log", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("container") != "browser" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("started\n"))
+	})
+	notFound := func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "NotFound", "code": 404}`))
+	}
+	mux.HandleFunc("/api/v1/namespaces/default/pods/missing", func(w http.ResponseWriter, r *http.Request) {
+		notFound(w)
+	})
+	mux.HandleFunc("/api/v1/namespaces/default/pods/deleted", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			notFound(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "deleted"}}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func testClient(t *testing.T, url string) *KubeClient {
+	k, err := newClient(context.Background(), &rest.Config{Host: url}, defaultNamespace, defaultTimeouts, func(string) {})
+	AssertThat(t, err, Is{nil})
+	return k
+}
+
+func TestNewClientVersion(t *testing.T) {
+	srv := apiServer()
+	defer srv.Close()
+	var determined string
+	_, err := newClient(context.Background(), &rest.Config{Host: srv.URL}, defaultNamespace, defaultTimeouts, func(v string) { determined = v })
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, determined, EqualTo{"v1.15.0"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = newClient(ctx, &rest.Config{Host: srv.URL}, defaultNamespace, defaultTimeouts, func(string) {})
+	AssertThat(t, err, Not{nil})
+}
+
+func TestGetLogs(t *testing.T) {
+	srv := apiServer()
+	defer srv.Close()
+	r, err := testClient(t, srv.URL).GetLogs(context.Background(), "session")
+	AssertThat(t, err, Is{nil})
+	data, _ := ioutil.ReadAll(r)
+	AssertThat(t, string(data), EqualTo{"started\n"})
+}
+
+func TestRequestTimeout(t *testing.T) {
+	srv := apiServer()
+	defer srv.Close()
+	k := testClient(t, srv.URL)
+	k.timeouts.Get = 50 * time.Millisecond
+	start := time.Now()
+	_, err := k.GetPodByName(context.Background(), "hung")
+	AssertThat(t, err, Not{nil})
+	AssertThat(t, time.Since(start) < 5*time.Second, Is{true})
+}
+
+func TestRequestCancelled(t *testing.T) {
+	srv := apiServer()
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := testClient(t, srv.URL).GetPodByName(ctx, "hung")
+	AssertThat(t, err, Not{nil})
+	AssertThat(t, time.Since(start) < 5*time.Second, Is{true})
+}
+
+func TestTimeoutsDefaults(t *testing.T) {
+	timeouts := Timeouts{Get: time.Second}.withDefaults()
+	AssertThat(t, timeouts.Get, EqualTo{time.Second})
+	AssertThat(t, timeouts.List, EqualTo{defaultTimeouts.List})
+}
@@ -0,0 +1,96 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// GetPodBySessionID finds the session pod labelled with the Selenium session
+// ID by AddSessionID.
+func (k *KubeClient) GetPodBySessionID(ctx context.Context, sessionID string) (*apiv1.Pod, error) {
+	if k.debug {
+		log.Printf("DEBUG: GetPodBySessionID: sessionID: %s", sessionID)
+	}
+
+	return k.getPodByLabel(ctx, defaultSeleniumSessionIDField, sessionID)
+}
+
+func (k *KubeClient) GetPodByRequestID(ctx context.Context, requestID string) (*apiv1.Pod, error) {
+	if k.debug {
+		log.Printf("DEBUG: GetPodByRequestID: requestID: %s", requestID)
+	}
+
+	return k.getPodByLabel(ctx, blueclient.RequestIDLabel, requestID)
+}
+
+// getPodByLabel returns a NotFound API error when no managed pod carries the
+// label and an error when the label is ambiguous.
+func (k *KubeClient) getPodByLabel(ctx context.Context, key, value string) (*apiv1.Pod, error) {
+	pods, ok := k.cachedPodsByLabel(key, value)
+	if !ok || len(pods) == 0 {
+		selector := blueclient.ManagedSelector()
+		selector[key] = value
+		list, err := k.listPods(ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(selector).String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		pods = list.Items
+	}
+	switch len(pods) {
+	case 0:
+		return nil, apierrors.NewNotFound(apiv1.Resource("pods"), fmt.Sprintf("%s=%s", key, value))
+	case 1:
+		return &pods[0], nil
+	default:
+		return nil, fmt.Errorf("%d pods found with %s=%s", len(pods), key, value)
+	}
+}
+
+// ListSessions returns every session pod created by BlueIO in the namespace.
+func (k *KubeClient) ListSessions(ctx context.Context) ([]blueclient.Session, error) {
+	if k.debug {
+		log.Printf("DEBUG: ListSessions")
+	}
+
+	pods, ok := k.cachedPodList()
+	if !ok {
+		list, err := k.listPods(ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(blueclient.ManagedSelector()).String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		pods = list.Items
+	}
+	sessions := make([]blueclient.Session, 0, len(pods))
+	for i := range pods {
+		sessions = append(sessions, podSession(&pods[i]))
+	}
+	return sessions, nil
+}
+
+func podSession(pod *apiv1.Pod) blueclient.Session {
+	s := blueclient.SessionFromLabels(pod.Name, pod.Labels, pod.Annotations)
+	s.State = string(pod.Status.Phase)
+	s.Finished = pod.Status.Phase == apiv1.PodSucceeded || pod.Status.Phase == apiv1.PodFailed
+	s.Created = pod.CreationTimestamp.Time
+	return s
+}
+
+func (k *KubeClient) RemoveSession(ctx context.Context, name string) error {
+	if k.debug {
+		log.Printf("DEBUG: RemoveSession: name: %s", name)
+	}
+
+	return k.DeletePod(ctx, name, DeleteOptions{IgnoreNotFound: true})
+}
@@ -0,0 +1,33 @@
+package kube
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodSession(t *testing.T) {
+	created := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	pod, _ := BuildSessionPod("42", "blueio/images:chrome_70.1", &SessionOptions{User: "alice"})
+	pod.Name = "blueio-abc"
+	pod.Labels[blueclient.SessionIDLabel] = "0123456789abcdef"
+	pod.CreationTimestamp = metav1.NewTime(created)
+	pod.Status.Phase = apiv1.PodFailed
+
+	AssertThat(t, podSession(pod), EqualTo{blueclient.Session{
+		ID:        "blueio-abc",
+		RequestID: "42",
+		SessionID: "0123456789abcdef",
+		Browser:   "chrome",
+		Version:   "70.1",
+		User:      "alice",
+		State:     "Failed",
+		Finished:  true,
+		Created:   created,
+	}})
+}
@@ -0,0 +1,220 @@
+package kube
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	metricsApiPath       = "/apis/metrics.k8s.io/v1beta1"
+	statsInterval        = 5 * time.Second
+	cgroupSampleInterval = 1 * time.Second
+)
+
+// cgroupScript prints cpu usage in nanoseconds, memory usage and memory limit
+// for cgroup v1 or v2 followed by /proc/net/dev.
+const cgroupScript = `if [ -f /sys/fs/cgroup/cpuacct/cpuacct.usage ]; then
+cat /sys/fs/cgroup/cpuacct/cpuacct.usage /sys/fs/cgroup/memory/memory.usage_in_bytes /sys/fs/cgroup/memory/memory.limit_in_bytes
+else
+echo $(( $(sed -n 's/^usage_usec //p' /sys/fs/cgroup/cpu.stat) * 1000 ))
+cat /sys/fs/cgroup/memory.current /sys/fs/cgroup/memory.max
+fi
+cat /proc/net/dev`
+
+type podMetrics struct {
+	Timestamp  metav1.Time `json:"timestamp"`
+	Containers []struct {
+		Name  string             `json:"name"`
+		Usage apiv1.ResourceList `json:"usage"`
+	} `json:"containers"`
+}
+
+type cgroupSample struct {
+	time   time.Time
+	cpu    uint64
+	memory uint64
+	limit  uint64
+	netRx  uint64
+	netTx  uint64
+}
+
+// Stats samples the pod through the metrics API, which reports no network
+// counters, and falls back to reading the cgroups inside the pod when the
+// API is not available.
+func (k *KubeClient) Stats(ctx context.Context, name string, stream bool) (<-chan blueclient.Stats, error) {
+	if k.debug {
+		log.Printf("DEBUG: Stats: name, stream: %s, %t", name, stream)
+	}
+
+	sample := k.metricsStats
+	first, err := sample(ctx, name)
+	if err != nil {
+		if k.debug {
+			log.Printf("DEBUG: Stats: metrics API unavailable, reading cgroups: %s", err)
+		}
+		var previous *cgroupSample
+		sample = func(ctx context.Context, name string) (*blueclient.Stats, error) {
+			return k.cgroupStats(ctx, name, &previous)
+		}
+		first, err = sample(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := make(chan blueclient.Stats, 1)
+	out <- *first
+	if !stream {
+		close(out)
+		return out, nil
+	}
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(statsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s, err := sample(ctx, name)
+				if err != nil {
+					if k.debug {
+						log.Printf("DEBUG: Stats: stopped for %s: %s", name, err)
+					}
+					return
+				}
+				select {
+				case out <- *s:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (k *KubeClient) metricsStats(ctx context.Context, name string) (*blueclient.Stats, error) {
+	data, err := k.clientset.CoreV1().RESTClient().Get().
+		AbsPath(metricsApiPath, "namespaces", k.namespace, "pods", name).
+		Context(ctx).
+		Timeout(k.timeouts.Get).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	var metrics podMetrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, err
+	}
+	pod, err := k.getPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &blueclient.Stats{ID: name, Time: metrics.Timestamp.Time}
+	for _, c := range metrics.Containers {
+		stats.CPUPercent += float64(c.Usage.Cpu().MilliValue()) / 10
+		stats.MemoryUsage += uint64(c.Usage.Memory().Value())
+	}
+	for _, c := range pod.Spec.Containers {
+		stats.MemoryLimit += uint64(c.Resources.Limits.Memory().Value())
+	}
+	return stats, nil
+}
+
+// cgroupStats reads the counters inside the pod. CPU usage is a rate, so the
+// first call takes two samples and later calls reuse the previous one.
+func (k *KubeClient) cgroupStats(ctx context.Context, name string, previous **cgroupSample) (*blueclient.Stats, error) {
+	if *previous == nil {
+		first, err := k.readCgroups(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		*previous = first
+		select {
+		case <-time.After(cgroupSampleInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	current, err := k.readCgroups(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	prev := *previous
+	*previous = current
+
+	stats := &blueclient.Stats{
+		ID:          name,
+		Time:        current.time,
+		MemoryUsage: current.memory,
+		MemoryLimit: current.limit,
+		NetworkRx:   current.netRx,
+		NetworkTx:   current.netTx,
+	}
+	elapsed := current.time.Sub(prev.time)
+	if elapsed > 0 && current.cpu > prev.cpu {
+		stats.CPUPercent = float64(current.cpu-prev.cpu) / float64(elapsed.Nanoseconds()) * 100
+	}
+	return stats, nil
+}
+
+func (k *KubeClient) readCgroups(ctx context.Context, name string) (*cgroupSample, error) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	err := k.exec(ctx, name, "", []string{"sh", "-c", cgroupScript}, nil, stdout, stderr)
+	if err != nil {
+		return nil, execError(err, stderr)
+	}
+	return parseCgroupSample(stdout.String(), time.Now())
+}
+
+func parseCgroupSample(output string, now time.Time) (*cgroupSample, error) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	var counters []uint64
+	for len(counters) < 3 && scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "max" {
+			counters = append(counters, 0)
+			continue
+		}
+		v, err := strconv.ParseUint(line, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected cgroup counter %q: %s", line, err)
+		}
+		counters = append(counters, v)
+	}
+	if len(counters) != 3 {
+		return nil, fmt.Errorf("incomplete cgroup output: %q", output)
+	}
+
+	sample := &cgroupSample{time: now, cpu: counters[0], memory: counters[1], limit: counters[2]}
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "lo" {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) < 9 {
+			continue
+		}
+		rx, _ := strconv.ParseUint(fields[0], 10, 64)
+		tx, _ := strconv.ParseUint(fields[8], 10, 64)
+		sample.netRx += rx
+		sample.netTx += tx
+	}
+	return sample, nil
+}
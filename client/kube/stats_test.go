@@ -0,0 +1,29 @@
+package kube
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+)
+
+const cgroupOutput = `123000000
+2048
+max
+Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0:    5000      50    0    0    0     0          0         0     7000      70    0    0    0     0       0          0
+`
+
+func TestParseCgroupSample(t *testing.T) {
+	now := time.Now()
+	sample, err := parseCgroupSample(cgroupOutput, now)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, *sample, EqualTo{cgroupSample{time: now, cpu: 123000000, memory: 2048, netRx: 5000, netTx: 7000}})
+}
+
+func TestParseIncompleteCgroupSample(t *testing.T) {
+	_, err := parseCgroupSample("123\n", time.Now())
+	AssertThat(t, err, Not{nil})
+}
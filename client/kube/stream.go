@@ -0,0 +1,200 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// Exec and port-forward streams go over a WebSocket speaking the channel
+// protocol of the API server: every message starts with the number of the
+// channel it carries.
+const channelProtocol = "v4.channel.k8s.io"
+
+const (
+	stdinChannel = iota
+	stdoutChannel
+	stderrChannel
+	errorChannel
+)
+
+// streamBufferSize bounds the messages written to a stream.
+const streamBufferSize = 32 * 1024
+
+// exitError is returned when the command exits with a non-zero code.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("command terminated with exit code %d", e.code)
+}
+
+// dialStream upgrades a GET on u to a WebSocket, authenticated like the
+// other requests of the client.
+func (k *KubeClient) dialStream(ctx context.Context, u *url.URL) (*websocket.Conn, error) {
+	tlsConfig, err := rest.TLSConfigFor(k.config)
+	if err != nil {
+		return nil, err
+	}
+	header, err := authHeader(k.config)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &websocket.Dialer{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+		Subprotocols:    []string{channelProtocol},
+		ReadBufferSize:  streamBufferSize,
+		WriteBufferSize: streamBufferSize,
+	}
+	wsURL := *u
+	switch u.Scheme {
+	case "https":
+		wsURL.Scheme = "wss"
+	case "http":
+		wsURL.Scheme = "ws"
+	}
+	conn, resp, err := dialer.DialContext(ctx, wsURL.String(), header)
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+			msg, _ := ioutil.ReadAll(resp.Body)
+			return nil, fmt.Errorf("%s: %v: %s", u.Path, err, strings.TrimSpace(string(msg)))
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// authHeader returns the headers the client adds to its requests, i.e. the
+// credentials and the user agent, which the WebSocket dialer has to send
+// itself.
+func authHeader(config *rest.Config) (http.Header, error) {
+	header := make(http.Header)
+	rt, err := rest.HTTPWrappersForConfig(config, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		header = req.Header
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, config.Host, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return header, nil
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// streamExec sends stdin to the command and copies its output to stdout and
+// stderr until the error channel reports how the command exited or the
+// connection is closed. Stdin is not read any more once it returns, but for
+// a read that is already blocked.
+func streamExec(conn *websocket.Conn, stdin io.Reader, stdout, stderr io.Writer) error {
+	if stdin != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go writeChannel(conn, stdinChannel, stdin, stop)
+	}
+	for {
+		_, data, err := conn.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(data) < 2 {
+			continue
+		}
+		switch data[0] {
+		case stdoutChannel:
+			if stdout != nil {
+				if _, err := stdout.Write(data[1:]); err != nil {
+					return err
+				}
+			}
+		case stderrChannel:
+			if stderr != nil {
+				if _, err := stderr.Write(data[1:]); err != nil {
+					return err
+				}
+			}
+		case errorChannel:
+			return execStatus(data[1:])
+		}
+	}
+}
+
+// writeChannel sends r to the channel until it ends or stop is closed. The
+// protocol has no way to close a channel, so the remote side never sees the
+// end of r.
+func writeChannel(conn *websocket.Conn, channel byte, r io.Reader, stop <-chan struct{}) error {
+	buf := make([]byte, streamBufferSize)
+	buf[0] = channel
+	for {
+		n, err := r.Read(buf[1:])
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		if n > 0 {
+			if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n+1]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// execStatus turns the status sent on the error channel into an error, an
+// exitError when the command ran and exited with a non-zero code.
+func execStatus(data []byte) error {
+	var status metav1.Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("cannot decode exec status: %v", err)
+	}
+	if status.Status == metav1.StatusSuccess {
+		return nil
+	}
+	if status.Reason == "NonZeroExitCode" && status.Details != nil {
+		for _, cause := range status.Details.Causes {
+			if cause.Type != "ExitCode" {
+				continue
+			}
+			code, err := strconv.Atoi(cause.Message)
+			if err == nil {
+				return &exitError{code: code}
+			}
+		}
+	}
+	return fmt.Errorf("exec failed: %s", status.Message)
+}
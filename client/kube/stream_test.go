@@ -0,0 +1,116 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+	"github.com/gorilla/websocket"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// execServer answers the exec requests of the session pod with handler.
+func execServer(handler func(conn *websocket.Conn, cmd []string)) *httptest.Server {
+	upgrader := websocket.Upgrader{Subprotocols: []string{channelProtocol}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/default/pods/session", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "session"}, "spec": {"containers": [{"name": "browser"}]}}`))
+	})
+	mux.HandleFunc("/api/v1/namespaces/default/pods/session/exec", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(conn, r.URL.Query()["command"])
+	})
+	return httptest.NewServer(mux)
+}
+
+func writeMessage(conn *websocket.Conn, channel byte, data string) {
+	conn.WriteMessage(websocket.BinaryMessage, append([]byte{channel}, data...))
+}
+
+func execClient(t *testing.T, url string) *KubeClient {
+	config := &rest.Config{Host: url}
+	cli, err := kubernetes.NewForConfig(config)
+	AssertThat(t, err, Is{nil})
+	return &KubeClient{
+		PodManager: cli.CoreV1().Pods(defaultNamespace),
+		config:     config,
+		clientset:  cli,
+		namespace:  defaultNamespace,
+	}
+}
+
+func TestExecStreams(t *testing.T) {
+	srv := execServer(func(conn *websocket.Conn, cmd []string) {
+		_, data, _ := conn.ReadMessage()
+		writeMessage(conn, stdoutChannel, string(data[1:]))
+		writeMessage(conn, stderrChannel, cmd[0])
+		writeMessage(conn, errorChannel, `{"status": "Success"}`)
+	})
+	defer srv.Close()
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	err := execClient(t, srv.URL).exec(context.Background(), "session", "", []string{"cat"}, bytes.NewBufferString("hello"), stdout, stderr)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, stdout.String(), EqualTo{"hello"})
+	AssertThat(t, stderr.String(), EqualTo{"cat"})
+}
+
+func TestExecExitCode(t *testing.T) {
+	srv := execServer(func(conn *websocket.Conn, cmd []string) {
+		writeMessage(conn, stderrChannel, "no such file")
+		writeMessage(conn, errorChannel, `{"status": "Failure", "reason": "NonZeroExitCode", "details": {"causes": [{"reason": "ExitCode", "message": "2"}]}}`)
+	})
+	defer srv.Close()
+
+	err := execClient(t, srv.URL).exec(context.Background(), "session", "", []string{"false"}, nil, nil, new(bytes.Buffer))
+	exitErr, ok := err.(*exitError)
+	AssertThat(t, ok, Is{true})
+	AssertThat(t, exitErr.code, EqualTo{2})
+}
+
+func TestExecCancelled(t *testing.T) {
+	cancelled := make(chan struct{})
+	srv := execServer(func(conn *websocket.Conn, cmd []string) {
+		<-cancelled
+		writeMessage(conn, stdoutChannel, "late")
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	stdin, _ := io.Pipe()
+	stdout := new(bytes.Buffer)
+	err := execClient(t, srv.URL).exec(ctx, "session", "", []string{"cat"}, stdin, stdout, nil)
+	close(cancelled)
+	AssertThat(t, err, Is{context.Canceled})
+	time.Sleep(50 * time.Millisecond)
+	AssertThat(t, stdout.Len(), EqualTo{0})
+}
+
+func TestCopyToReadsArchiveSize(t *testing.T) {
+	cmds := make(chan []string, 1)
+	srv := execServer(func(conn *websocket.Conn, cmd []string) {
+		cmds <- cmd
+		writeMessage(conn, errorChannel, `{"status": "Success"}`)
+	})
+	defer srv.Close()
+
+	err := execClient(t, srv.URL).CopyTo(context.Background(), "session", "/tmp", bytes.NewBufferString("12345"))
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, (<-cmds)[4:], EqualTo{[]string{"5", "/tmp"}})
+}
@@ -0,0 +1,130 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// waiting reasons after which a container will not start without intervention
+var fatalWaitingReasons = map[string]bool{
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// WaitReady waits until the pod is running, has an IP and all its containers
+// are ready.
+func (k *KubeClient) WaitReady(ctx context.Context, name string) (*blueclient.Endpoint, error) {
+	if k.debug {
+		log.Printf("DEBUG: WaitReady: name: %s", name)
+	}
+
+	for {
+		pod, err := k.getPod(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		ep, err := podReadiness(pod)
+		if ep != nil || err != nil {
+			return ep, err
+		}
+
+		w, err := k.watchPods(ctx, metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", name).String(),
+			ResourceVersion: pod.ResourceVersion,
+		})
+		if err != nil {
+			return nil, err
+		}
+		ep, err = waitPodReady(ctx, w, name)
+		w.Stop()
+		if ep != nil || err != nil {
+			return ep, err
+		}
+		if k.debug {
+			log.Printf("DEBUG: WaitReady: watch for %s expired, restarting", name)
+		}
+	}
+}
+
+// waitPodReady returns nil, nil when the watch ends before the pod is decided.
+func waitPodReady(ctx context.Context, w watch.Interface, name string) (*blueclient.Endpoint, error) {
+	for {
+		select {
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return nil, nil
+			}
+			switch e.Type {
+			case watch.Deleted:
+				return nil, &blueclient.NotReadyError{ID: name, Reason: "deleted"}
+			case watch.Error:
+				return nil, fmt.Errorf("watching pod %s: %v", name, e.Object)
+			}
+			pod, ok := e.Object.(*apiv1.Pod)
+			if !ok {
+				continue
+			}
+			ep, err := podReadiness(pod)
+			if ep != nil || err != nil {
+				return ep, err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// podReadiness returns the endpoint of a ready pod, an error for a pod that
+// will never get ready and nil, nil otherwise.
+func podReadiness(pod *apiv1.Pod) (*blueclient.Endpoint, error) {
+	notReady := func(reason, message string) error {
+		return &blueclient.NotReadyError{ID: pod.Name, Reason: reason, Message: message}
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == apiv1.PodScheduled && condition.Status == apiv1.ConditionFalse &&
+			condition.Reason == apiv1.PodReasonUnschedulable {
+			return nil, notReady(condition.Reason, condition.Message)
+		}
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if waiting := status.State.Waiting; waiting != nil && fatalWaitingReasons[waiting.Reason] {
+			return nil, notReady(waiting.Reason, waiting.Message)
+		}
+		if terminated := status.State.Terminated; terminated != nil {
+			return nil, notReady(terminated.Reason, fmt.Sprintf("container %s exited with code %d", status.Name, terminated.ExitCode))
+		}
+	}
+	switch pod.Status.Phase {
+	case apiv1.PodFailed, apiv1.PodSucceeded:
+		return nil, notReady(string(pod.Status.Phase), pod.Status.Message)
+	case apiv1.PodRunning:
+		if pod.Status.PodIP == "" || !podReady(pod) {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+	return podEndpoint(pod), nil
+}
+
+func podEndpoint(pod *apiv1.Pod) *blueclient.Endpoint {
+	ep := &blueclient.Endpoint{IP: pod.Status.PodIP, Ports: make(map[int]int)}
+	for _, c := range pod.Spec.Containers {
+		for _, port := range c.Ports {
+			ep.Ports[int(port.ContainerPort)] = int(port.ContainerPort)
+		}
+	}
+	return ep
+}
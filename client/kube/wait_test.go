@@ -0,0 +1,68 @@
+package kube
+
+import (
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func sessionPod() *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "blueio-abc"},
+		Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{Ports: []apiv1.ContainerPort{{ContainerPort: 4444}}}},
+		},
+		Status: apiv1.PodStatus{Phase: apiv1.PodPending},
+	}
+}
+
+func TestPodReadinessPending(t *testing.T) {
+	ep, err := podReadiness(sessionPod())
+	AssertThat(t, ep == nil, Is{true})
+	AssertThat(t, err, Is{nil})
+}
+
+func TestPodReadinessReady(t *testing.T) {
+	pod := sessionPod()
+	pod.Status.Phase = apiv1.PodRunning
+	pod.Status.PodIP = "10.1.2.3"
+	pod.Status.Conditions = []apiv1.PodCondition{{Type: apiv1.PodReady, Status: apiv1.ConditionTrue}}
+	ep, err := podReadiness(pod)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, *ep, EqualTo{blueclient.Endpoint{IP: "10.1.2.3", Ports: map[int]int{4444: 4444}}})
+}
+
+func TestPodReadinessImagePullBackOff(t *testing.T) {
+	pod := sessionPod()
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{State: apiv1.ContainerState{
+		Waiting: &apiv1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "not found"},
+	}}}
+	_, err := podReadiness(pod)
+	AssertThat(t, err.Error(), EqualTo{"blueio-abc will not become ready: ImagePullBackOff: not found"})
+}
+
+func TestPodReadinessUnschedulable(t *testing.T) {
+	pod := sessionPod()
+	pod.Status.Conditions = []apiv1.PodCondition{{
+		Type:    apiv1.PodScheduled,
+		Status:  apiv1.ConditionFalse,
+		Reason:  apiv1.PodReasonUnschedulable,
+		Message: "0/3 nodes are available",
+	}}
+	_, err := podReadiness(pod)
+	AssertThat(t, err.Error(), EqualTo{"blueio-abc will not become ready: Unschedulable: 0/3 nodes are available"})
+}
+
+func TestPodReadinessExited(t *testing.T) {
+	pod := sessionPod()
+	pod.Status.Phase = apiv1.PodFailed
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{Name: "browser", State: apiv1.ContainerState{
+		Terminated: &apiv1.ContainerStateTerminated{ExitCode: 2, Reason: "Error"},
+	}}}
+	_, err := podReadiness(pod)
+	AssertThat(t, err.Error(), EqualTo{"blueio-abc will not become ready: Error: container browser exited with code 2"})
+}
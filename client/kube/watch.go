@@ -0,0 +1,215 @@
+package kube
+
+import (
+	"context"
+	"log"
+	"time"
+
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const oomKilledReason = "OOMKilled"
+
+// podState remembers which events were already emitted for a pod, as the
+// watch reports the whole object on every change.
+type podState struct {
+	started bool
+	ready   bool
+	exited  bool
+	// pod as last seen, reported when it is deleted unseen
+	pod *apiv1.Pod
+}
+
+// Watch reports the events of the pods matching the selector until the
+// context is done. Watches expire, the pods are listed again then not to
+// miss what happened in between and watched from the listing.
+func (k *KubeClient) Watch(ctx context.Context, selector map[string]string) (<-chan blueclient.Event, error) {
+	if k.debug {
+		log.Printf("DEBUG: Watch: selector: %v", selector)
+	}
+
+	opts := metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(selector).String(),
+	}
+	w, err := k.watchPods(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan blueclient.Event)
+	go func() {
+		defer close(out)
+		states := make(map[string]*podState)
+		send := func(events []blueclient.Event) bool {
+			for _, event := range events {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		}
+		for w != nil {
+			done := watchEvents(ctx, w, states, send)
+			w.Stop()
+			if done {
+				return
+			}
+			if k.debug {
+				log.Printf("DEBUG: Watch: result channel closed, listing again")
+			}
+			w = k.rewatch(ctx, opts, states, send)
+		}
+	}()
+	return out, nil
+}
+
+// watchEvents sends the events of the watch until it ends and returns true
+// when the context is done or the receiver is gone.
+func watchEvents(ctx context.Context, w watch.Interface, states map[string]*podState, send func([]blueclient.Event) bool) bool {
+	for {
+		select {
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return false
+			}
+			pod, ok := e.Object.(*apiv1.Pod)
+			if !ok {
+				continue
+			}
+			if !send(podEvents(states, e.Type, pod)) {
+				return true
+			}
+		case <-ctx.Done():
+			return true
+		}
+	}
+}
+
+// rewatch lists the pods, sends what changed since the last event and
+// watches from the listing. It retries until the context is done and
+// returns nil then or when the receiver is gone.
+func (k *KubeClient) rewatch(ctx context.Context, opts metav1.ListOptions, states map[string]*podState, send func([]blueclient.Event) bool) watch.Interface {
+	for {
+		list, err := k.listPods(ctx, opts)
+		if err == nil {
+			if !send(resyncEvents(states, list.Items)) {
+				return nil
+			}
+			watchOpts := opts
+			watchOpts.ResourceVersion = list.ResourceVersion
+			var w watch.Interface
+			w, err = k.watchPods(ctx, watchOpts)
+			if err == nil {
+				return w
+			}
+		}
+		log.Printf("WARNING: cannot watch pods %s: %v", opts.LabelSelector, err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(relistInterval):
+		}
+	}
+}
+
+// resyncEvents reports the listed pods, the known pods missing from the
+// list were deleted.
+func resyncEvents(states map[string]*podState, pods []apiv1.Pod) []blueclient.Event {
+	var events []blueclient.Event
+	listed := make(map[string]bool)
+	for i := range pods {
+		pod := &pods[i]
+		listed[pod.Name] = true
+		eventType := watch.Modified
+		if _, ok := states[pod.Name]; !ok {
+			eventType = watch.Added
+		}
+		events = append(events, podEvents(states, eventType, pod)...)
+	}
+	for name, state := range states {
+		if !listed[name] {
+			events = append(events, podEvents(states, watch.Deleted, state.pod)...)
+		}
+	}
+	return events
+}
+
+func podEvents(states map[string]*podState, eventType watch.EventType, pod *apiv1.Pod) []blueclient.Event {
+	event := func(t blueclient.EventType) blueclient.Event {
+		return blueclient.Event{
+			Type:   t,
+			ID:     pod.Name,
+			Name:   pod.Name,
+			Labels: pod.Labels,
+			Time:   time.Now(),
+		}
+	}
+
+	var events []blueclient.Event
+	state, ok := states[pod.Name]
+	if !ok {
+		state = &podState{}
+		states[pod.Name] = state
+		if eventType == watch.Added {
+			events = append(events, event(blueclient.EventCreated))
+		}
+	}
+	state.pod = pod
+	if eventType == watch.Deleted {
+		delete(states, pod.Name)
+		return append(events, event(blueclient.EventDeleted))
+	}
+
+	if !state.started && podStarted(pod) {
+		state.started = true
+		events = append(events, event(blueclient.EventStarted))
+	}
+	if !state.ready && podReady(pod) {
+		state.ready = true
+		events = append(events, event(blueclient.EventReady))
+	}
+	if terminated := podTerminated(pod); !state.exited && terminated != nil {
+		state.exited = true
+		if terminated.Reason == oomKilledReason {
+			events = append(events, event(blueclient.EventOOMKilled))
+		}
+		exited := event(blueclient.EventExited)
+		exited.ExitCode = int(terminated.ExitCode)
+		events = append(events, exited)
+	}
+	return events
+}
+
+func podStarted(pod *apiv1.Pod) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running != nil || status.State.Terminated != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func podReady(pod *apiv1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == apiv1.PodReady {
+			return condition.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func podTerminated(pod *apiv1.Pod) *apiv1.ContainerStateTerminated {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil {
+			return status.State.Terminated
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package kube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+	blueclient "github.com/kolobok01/util/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func eventTypes(events []blueclient.Event) []blueclient.EventType {
+	var types []blueclient.EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestPodEvents(t *testing.T) {
+	states := make(map[string]*podState)
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "blueio-abc"}}
+	events := podEvents(states, watch.Added, pod)
+	AssertThat(t, eventTypes(events), EqualTo{[]blueclient.EventType{blueclient.EventCreated}})
+	AssertThat(t, events[0].ID, EqualTo{"blueio-abc"})
+
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{State: apiv1.ContainerState{Running: &apiv1.ContainerStateRunning{}}}}
+	pod.Status.Conditions = []apiv1.PodCondition{{Type: apiv1.PodReady, Status: apiv1.ConditionTrue}}
+	AssertThat(t, eventTypes(podEvents(states, watch.Modified, pod)), EqualTo{[]blueclient.EventType{blueclient.EventStarted, blueclient.EventReady}})
+	AssertThat(t, len(podEvents(states, watch.Modified, pod)), EqualTo{0})
+
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}}}}
+	events = podEvents(states, watch.Modified, pod)
+	AssertThat(t, eventTypes(events), EqualTo{[]blueclient.EventType{blueclient.EventOOMKilled, blueclient.EventExited}})
+	AssertThat(t, events[1].ExitCode, EqualTo{137})
+
+	AssertThat(t, eventTypes(podEvents(states, watch.Deleted, pod)), EqualTo{[]blueclient.EventType{blueclient.EventDeleted}})
+	AssertThat(t, len(states), EqualTo{0})
+}
+
+func TestResyncEvents(t *testing.T) {
+	states := make(map[string]*podState)
+	podEvents(states, watch.Added, &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "blueio-a"}})
+	events := resyncEvents(states, []apiv1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "blueio-b"}}})
+	AssertThat(t, eventTypes(events), EqualTo{[]blueclient.EventType{blueclient.EventCreated, blueclient.EventDeleted}})
+	AssertThat(t, events[1].Name, EqualTo{"blueio-a"})
+	AssertThat(t, len(states), EqualTo{1})
+}
+
+func TestWatchListsAgainWhenWatchExpires(t *testing.T) {
+	first := make(chan struct{}, 1)
+	first <- struct{}{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"major": "1", "minor": "15", "gitVersion": "v1.15.0"}`))
+	})
+	mux.HandleFunc("/api/v1/namespaces/default/pods", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "" {
+			w.Write([]byte(`{"kind": "PodList", "apiVersion": "v1", "metadata": {"resourceVersion": "2"}, "items": [{"metadata": {"name": "blueio-b"}}]}`))
+			return
+		}
+		select {
+		case <-first:
+			w.Write([]byte(`{"type": "ADDED", "object": {"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "blueio-a"}}}`))
+		default:
+			<-r.Context().Done()
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := testClient(t, srv.URL).Watch(ctx, map[string]string{})
+	AssertThat(t, err, Is{nil})
+	var got []string
+	for i := 0; i < 3; i++ {
+		e := <-events
+		got = append(got, string(e.Type)+" "+e.ID)
+	}
+	AssertThat(t, got, EqualTo{[]string{
+		string(blueclient.EventCreated) + " blueio-a",
+		string(blueclient.EventCreated) + " blueio-b",
+		string(blueclient.EventDeleted) + " blueio-a",
+	}})
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,18 @@
+package client
+
+// Labels and annotations put on every session container or pod so that
+// sessions can be found again across restarts and replicas.
+const (
+	ManagedLabel   = "blueio"
+	ManagedValue   = "true"
+	RequestIDLabel = "blueio.request-id"
+	BrowserLabel   = "blueio.browser"
+	VersionLabel   = "blueio.version"
+	SessionIDLabel = "Selenium"
+	UserAnnotation = "blueio.user"
+)
+
+// ManagedSelector selects every session created by BlueIO.
+func ManagedSelector() map[string]string {
+	return map[string]string{ManagedLabel: ManagedValue}
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultWebDriverPort    = 4444
+	DefaultStatusPath       = "/status"
+	defaultProbeInterval    = 100 * time.Millisecond
+	defaultMaxProbeInterval = 2 * time.Second
+)
+
+// Probe checks that the WebDriver inside a freshly started browser container
+// accepts sessions. The zero value polls http://<ip>:4444/status.
+type Probe struct {
+	Port int
+	Path string
+	// TCP only checks that the port accepts connections.
+	TCP bool
+	// Intervals between attempts start at InitialInterval and double up to
+	// MaxInterval.
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// Timeout bounds every attempt, MaxInterval by default, a WebDriver
+	// hanging on an attempt would otherwise stall the probe.
+	Timeout time.Duration
+	Client  *http.Client
+}
+
+// WaitSession waits for the container to be ready and then for its WebDriver
+// to answer the probe.
+func WaitSession(ctx context.Context, c Client, id string, probe Probe) (*Endpoint, error) {
+	ep, err := c.WaitReady(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := probe.Wait(ctx, ep); err != nil {
+		return nil, err
+	}
+	return ep, nil
+}
+
+// StatusPath returns the status endpoint of a WebDriver served under the
+// base path, like /wd/hub for Selenium 3 images.
+func StatusPath(base string) string {
+	return strings.TrimSuffix(base, "/") + DefaultStatusPath
+}
+
+// Wait polls the endpoint with exponential backoff until the probe succeeds
+// or the context is done.
+func (p Probe) Wait(ctx context.Context, ep *Endpoint) error {
+	port := p.Port
+	if port == 0 {
+		port = DefaultWebDriverPort
+	}
+	address := ep.Address(port)
+	interval := p.InitialInterval
+	if interval <= 0 {
+		interval = defaultProbeInterval
+	}
+	maxInterval := p.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = defaultMaxProbeInterval
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = maxInterval
+	}
+
+	for {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := p.check(attemptCtx, address)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return fmt.Errorf("webdriver at %s is not ready: %v", address, err)
+		}
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+func (p Probe) check(ctx context.Context, address string) error {
+	if p.TCP {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	path := p.Path
+	if path == "" {
+		path = DefaultStatusPath
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://"+address+path, nil)
+	if err != nil {
+		return err
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status endpoint returned %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return checkStatus(data)
+}
+
+// checkStatus rejects W3C status responses reporting "ready": false, legacy
+// JSON Wire responses have no ready flag and are accepted.
+func checkStatus(data []byte) error {
+	var status struct {
+		Value struct {
+			Ready   *bool  `json:"ready"`
+			Message string `json:"message"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil
+	}
+	if ready := status.Value.Ready; ready != nil && !*ready {
+		return fmt.Errorf("webdriver is not ready: %s", status.Value.Message)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+)
+
+type readyClient struct {
+	Client
+	ep  *Endpoint
+	err error
+}
+
+func (c *readyClient) WaitReady(ctx context.Context, id string) (*Endpoint, error) {
+	return c.ep, c.err
+}
+
+func endpointOf(t *testing.T, srv *httptest.Server) (*Endpoint, int) {
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	AssertThat(t, err, Is{nil})
+	p, _ := strconv.Atoi(port)
+	return &Endpoint{IP: host}, p
+}
+
+func TestProbeWaitsForReadyStatus(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AssertThat(t, r.URL.Path, EqualTo{"/wd/hub/status"})
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Write([]byte(`{"value": {"ready": false, "message": "starting"}}`))
+			return
+		}
+		w.Write([]byte(`{"value": {"ready": true}}`))
+	}))
+	defer srv.Close()
+
+	ep, port := endpointOf(t, srv)
+	probe := Probe{Port: port, Path: "/wd/hub/status", InitialInterval: time.Millisecond}
+	got, err := WaitSession(context.Background(), &readyClient{ep: ep}, "some-id", probe)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, got, EqualTo{ep})
+	AssertThat(t, atomic.LoadInt32(&calls), EqualTo{int32(3)})
+}
+
+func TestProbeTimesOutHungAttempts(t *testing.T) {
+	var calls int32
+	hung := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-hung
+			return
+		}
+		w.Write([]byte(`{"value": {"ready": true}}`))
+	}))
+	defer srv.Close()
+	defer close(hung)
+
+	ep, port := endpointOf(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	probe := Probe{Port: port, InitialInterval: time.Millisecond, Timeout: 50 * time.Millisecond}
+	AssertThat(t, probe.Wait(ctx, ep), Is{nil})
+	AssertThat(t, atomic.LoadInt32(&calls), EqualTo{int32(2)})
+}
+
+func TestProbeLegacyStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": 0, "value": {"build": {"version": "3.141.59"}}}`))
+	}))
+	defer srv.Close()
+
+	ep, port := endpointOf(t, srv)
+	AssertThat(t, Probe{Port: port}.Wait(context.Background(), ep), Is{nil})
+}
+
+func TestProbeTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	AssertThat(t, err, Is{nil})
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+	AssertThat(t, Probe{Port: port, TCP: true}.Wait(context.Background(), &Endpoint{IP: "127.0.0.1"}), Is{nil})
+}
+
+func TestProbeTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ep, port := endpointOf(t, srv)
+	err := Probe{Port: port, InitialInterval: time.Millisecond}.Wait(ctx, ep)
+	AssertThat(t, err, Not{nil})
+}
+
+func TestWaitSessionNotReady(t *testing.T) {
+	notReady := &NotReadyError{ID: "some-id", Reason: "exited"}
+	_, err := WaitSession(context.Background(), &readyClient{err: notReady}, "some-id", Probe{})
+	AssertThat(t, err, EqualTo{notReady})
+}
+
+func TestStatusPath(t *testing.T) {
+	AssertThat(t, StatusPath(""), EqualTo{"/status"})
+	AssertThat(t, StatusPath("/"), EqualTo{"/status"})
+	AssertThat(t, StatusPath("/wd/hub"), EqualTo{"/wd/hub/status"})
+	AssertThat(t, StatusPath("/wd/hub/"), EqualTo{"/wd/hub/status"})
+}
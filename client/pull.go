@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kolobok01/util/sse"
+)
+
+// PullProgress is a step of an image pull. The last progress of a failed
+// pull carries the error.
+type PullProgress struct {
+	Image string `json:"image"`
+	// Node is the Kubernetes node or the Docker host of a pool pulling the
+	// image.
+	Node string `json:"node,omitempty"`
+	// Layer is set for the progress of a single layer on Docker.
+	Layer   string    `json:"layer,omitempty"`
+	Status  string    `json:"status"`
+	Current int64     `json:"current,omitempty"`
+	Total   int64     `json:"total,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// ImageInfo is a browser image present on a Docker host or a node.
+type ImageInfo struct {
+	Image   string    `json:"image"`
+	ID      string    `json:"id,omitempty"`
+	Browser string    `json:"browser"`
+	Version string    `json:"version"`
+	Size    int64     `json:"size"`
+	Node    string    `json:"node,omitempty"`
+	Created time.Time `json:"created,omitempty"`
+	// InUse is only reported by Docker.
+	InUse bool `json:"inUse"`
+}
+
+// ImageManager is implemented by clients able to pull browser images ahead
+// of the sessions needing them.
+type ImageManager interface {
+	// PullImage starts the pull, the channel is closed once it is over.
+	PullImage(ctx context.Context, image string) (<-chan PullProgress, error)
+	// ListImages returns the present images that look like browser images.
+	ListImages(ctx context.Context) ([]ImageInfo, error)
+}
+
+// ImagePruner is implemented by clients managing the image storage
+// themselves, Kubernetes leaves it to the image garbage collection of the
+// kubelet.
+type ImagePruner interface {
+	// RemoveUnusedImages removes the browser images older than the given age
+	// that no container uses and returns them.
+	RemoveUnusedImages(ctx context.Context, olderThan time.Duration) ([]string, error)
+}
+
+// PrePull pulls the images one after another and passes their progress to
+// fn, which may be nil. A failed pull does not stop the others.
+func PrePull(ctx context.Context, m ImageManager, images []string, fn func(PullProgress)) error {
+	var failed []string
+	for _, image := range images {
+		progress, err := m.PullImage(ctx, image)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", image, err))
+			continue
+		}
+		var pullErr string
+		for p := range progress {
+			if p.Error != "" {
+				pullErr = p.Error
+			}
+			if fn != nil {
+				fn(p)
+			}
+		}
+		if pullErr != "" {
+			failed = append(failed, fmt.Sprintf("%s: %s", image, pullErr))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("cannot pull images: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// PublishPullProgress forwards every progress to the broker as JSON until
+// the channel is closed.
+func PublishPullProgress(broker sse.Broker, progress <-chan PullProgress) {
+	for p := range progress {
+		data, err := json.Marshal(p)
+		if err != nil {
+			log.Printf("ERROR: cannot marshal pull progress %+v: %v", p, err)
+			continue
+		}
+		broker.Notify(data)
+	}
+}
+
+// BrowserImage fills the browser and the version of an image, it returns
+// false for images that are not browser images.
+func BrowserImage(info *ImageInfo) bool {
+	browser, version, err := ParseImage(info.Image)
+	if err != nil {
+		return false
+	}
+	info.Browser, info.Version = browser, version
+	return true
+}
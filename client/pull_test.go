@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+)
+
+type mockImages map[string]string
+
+func (m mockImages) PullImage(ctx context.Context, image string) (<-chan PullProgress, error) {
+	pullErr, ok := m[image]
+	if !ok {
+		return nil, errors.New("no such registry")
+	}
+	progress := make(chan PullProgress, 2)
+	progress <- PullProgress{Image: image, Status: "Pulling"}
+	progress <- PullProgress{Image: image, Status: "Done", Error: pullErr}
+	close(progress)
+	return progress, nil
+}
+
+func (m mockImages) ListImages(ctx context.Context) ([]ImageInfo, error) {
+	return nil, nil
+}
+
+func TestPrePull(t *testing.T) {
+	var pulled []string
+	err := PrePull(context.Background(), mockImages{"a:chrome_70.0": "", "b:firefox_63.0": ""}, []string{"a:chrome_70.0", "b:firefox_63.0"}, func(p PullProgress) {
+		pulled = append(pulled, p.Image+" "+p.Status)
+	})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, pulled, EqualTo{[]string{"a:chrome_70.0 Pulling", "a:chrome_70.0 Done", "b:firefox_63.0 Pulling", "b:firefox_63.0 Done"}})
+}
+
+func TestPrePullContinuesAfterFailure(t *testing.T) {
+	images := mockImages{"a:chrome_70.0": "manifest unknown", "c:opera_56.0": ""}
+	err := PrePull(context.Background(), images, []string{"a:chrome_70.0", "b:firefox_63.0", "c:opera_56.0"}, nil)
+	AssertThat(t, err, Not{nil})
+	AssertThat(t, err.Error(), EqualTo{"cannot pull images: a:chrome_70.0: manifest unknown; b:firefox_63.0: no such registry"})
+}
+
+func TestBrowserImage(t *testing.T) {
+	info := ImageInfo{Image: "blueio/images:chrome_70.0", Created: time.Now()}
+	AssertThat(t, BrowserImage(&info), Is{true})
+	AssertThat(t, info.Browser, EqualTo{"chrome"})
+	AssertThat(t, info.Version, EqualTo{"70.0"})
+	AssertThat(t, BrowserImage(&ImageInfo{Image: "nginx:latest"}), Is{false})
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	ReasonExpired  = "expired"
+	ReasonFinished = "finished"
+	ReasonUnknown  = "unknown"
+
+	defaultReapInterval = time.Minute
+	defaultUnknownGrace = time.Minute
+)
+
+// SessionManager is implemented by clients that can enumerate and remove the
+// sessions they created.
+type SessionManager interface {
+	ListSessions(ctx context.Context) ([]Session, error)
+	RemoveSession(ctx context.Context, id string) error
+}
+
+type ReaperOptions struct {
+	// MaxAge removes sessions older than that, zero disables the check.
+	MaxAge time.Duration
+	// Known reports whether the caller still tracks the session, sessions it
+	// does not know are removed. Nil disables the check.
+	Known func(Session) bool
+	// UnknownGrace keeps unknown sessions younger than that, they may still
+	// be starting. One minute by default.
+	UnknownGrace time.Duration
+	// KeepFinished leaves completed and failed sessions alone.
+	KeepFinished bool
+	// Interval between runs of Run, one minute by default.
+	Interval time.Duration
+	// DryRun reports the sessions that would be removed without removing them.
+	DryRun bool
+}
+
+// Removal is a session the reaper removed or, in dry-run mode, would remove.
+type Removal struct {
+	Session Session `json:"session"`
+	Reason  string  `json:"reason"`
+	Error   string  `json:"error,omitempty"`
+}
+
+type ReapReport struct {
+	Time    time.Time `json:"time"`
+	DryRun  bool      `json:"dryRun"`
+	Checked int       `json:"checked"`
+	Removed []Removal `json:"removed"`
+}
+
+// Reaper removes sessions left behind by a crashed or restarted hub.
+type Reaper struct {
+	manager SessionManager
+	opts    ReaperOptions
+	now     func() time.Time
+}
+
+func NewReaper(manager SessionManager, opts ReaperOptions) *Reaper {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultReapInterval
+	}
+	if opts.UnknownGrace <= 0 {
+		opts.UnknownGrace = defaultUnknownGrace
+	}
+	return &Reaper{manager: manager, opts: opts, now: time.Now}
+}
+
+// Reap lists the sessions once and removes the orphaned ones. Failed removals
+// are recorded in the report, they do not stop the run.
+func (r *Reaper) Reap(ctx context.Context) (*ReapReport, error) {
+	sessions, err := r.manager.ListSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := r.now()
+	report := &ReapReport{Time: now, DryRun: r.opts.DryRun, Checked: len(sessions)}
+	for _, s := range sessions {
+		reason := r.reason(s, now.Sub(s.Created))
+		if reason == "" {
+			continue
+		}
+		removal := Removal{Session: s, Reason: reason}
+		if !r.opts.DryRun {
+			if err := r.manager.RemoveSession(ctx, s.ID); err != nil {
+				removal.Error = err.Error()
+			}
+		}
+		report.Removed = append(report.Removed, removal)
+	}
+	return report, nil
+}
+
+func (r *Reaper) reason(s Session, age time.Duration) string {
+	switch {
+	case s.Finished && !r.opts.KeepFinished:
+		return ReasonFinished
+	case r.opts.MaxAge > 0 && age > r.opts.MaxAge:
+		return ReasonExpired
+	case r.opts.Known != nil && age > r.opts.UnknownGrace && !r.opts.Known(s):
+		return ReasonUnknown
+	}
+	return ""
+}
+
+// Run reaps every Interval until the context is done, passing each report
+// to the callback when it is not nil.
+func (r *Reaper) Run(ctx context.Context, callback func(*ReapReport)) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		report, err := r.Reap(ctx)
+		if err != nil {
+			log.Printf("ERROR: cannot list sessions: %v", err)
+		} else if callback != nil {
+			callback(report)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+)
+
+type mockManager struct {
+	sessions []Session
+	removed  []string
+	failOn   string
+}
+
+func (m *mockManager) ListSessions(ctx context.Context) ([]Session, error) {
+	return m.sessions, nil
+}
+
+func (m *mockManager) RemoveSession(ctx context.Context, id string) error {
+	if id == m.failOn {
+		return errors.New("cannot remove")
+	}
+	m.removed = append(m.removed, id)
+	return nil
+}
+
+var reapNow = time.Date(2019, 1, 2, 12, 0, 0, 0, time.UTC)
+
+func testManager() *mockManager {
+	return &mockManager{sessions: []Session{
+		{ID: "fresh", SessionID: "a", Created: reapNow.Add(-10 * time.Second)},
+		{ID: "known", SessionID: "b", Created: reapNow.Add(-10 * time.Minute)},
+		{ID: "old", SessionID: "b", Created: reapNow.Add(-2 * time.Hour)},
+		{ID: "finished", SessionID: "b", Finished: true, Created: reapNow.Add(-time.Minute)},
+		{ID: "unknown", SessionID: "c", Created: reapNow.Add(-10 * time.Minute)},
+	}}
+}
+
+func testReaper(m SessionManager, opts ReaperOptions) *Reaper {
+	r := NewReaper(m, opts)
+	r.now = func() time.Time { return reapNow }
+	return r
+}
+
+func reasons(report *ReapReport) map[string]string {
+	result := make(map[string]string)
+	for _, removal := range report.Removed {
+		result[removal.Session.ID] = removal.Reason
+	}
+	return result
+}
+
+func TestReap(t *testing.T) {
+	m := testManager()
+	r := testReaper(m, ReaperOptions{
+		MaxAge: time.Hour,
+		Known:  func(s Session) bool { return s.SessionID == "b" },
+	})
+	report, err := r.Reap(context.Background())
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, report.Checked, EqualTo{5})
+	AssertThat(t, reasons(report), EqualTo{map[string]string{
+		"old":      ReasonExpired,
+		"finished": ReasonFinished,
+		"unknown":  ReasonUnknown,
+	}})
+	AssertThat(t, m.removed, EqualTo{[]string{"old", "finished", "unknown"}})
+}
+
+func TestReapDryRun(t *testing.T) {
+	m := testManager()
+	report, err := testReaper(m, ReaperOptions{DryRun: true}).Reap(context.Background())
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, report.DryRun, Is{true})
+	AssertThat(t, reasons(report), EqualTo{map[string]string{"finished": ReasonFinished}})
+	AssertThat(t, len(m.removed), EqualTo{0})
+}
+
+func TestReapRemovalError(t *testing.T) {
+	m := testManager()
+	m.failOn = "finished"
+	report, err := testReaper(m, ReaperOptions{}).Reap(context.Background())
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, report.Removed[0].Error, EqualTo{"cannot remove"})
+}
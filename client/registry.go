@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Options struct {
+	// Type selects a registered backend and skips auto-detection.
+	Type  string
+	Debug bool
+	// OnVersion receives the API version negotiated with the backend.
+	OnVersion func(version string)
+	// OnRejected receives every candidate backend that was skipped during
+	// auto-detection together with the reason.
+	OnRejected func(backend string, reason error)
+}
+
+// Backend describes how to detect and create a Client of the type it
+// reports from GetType.
+type Backend struct {
+	Type string
+	// Priority orders auto-detection, lower values are tried first.
+	Priority int
+	// Detect returns nil when the environment looks suitable for the
+	// backend or an error explaining why it is not.
+	Detect func(ctx context.Context) error
+	New    func(ctx context.Context, opts Options) (Client, error)
+}
+
+// DetectError is returned by New when no backend could be used.
+type DetectError struct {
+	Rejected []Rejection
+}
+
+type Rejection struct {
+	Backend string
+	Reason  error
+}
+
+func (e *DetectError) Error() string {
+	if len(e.Rejected) == 0 {
+		return "no client backends registered"
+	}
+	var reasons []string
+	for _, r := range e.Rejected {
+		reasons = append(reasons, fmt.Sprintf("%s: %v", r.Backend, r.Reason))
+	}
+	return fmt.Sprintf("no suitable client backend found (%s)", strings.Join(reasons, "; "))
+}
+
+type Registry struct {
+	mu       sync.RWMutex
+	backends map[string]Backend
+}
+
+func NewRegistry() *Registry {
+	return &Registry{backends: make(map[string]Backend)}
+}
+
+var registry = NewRegistry()
+
+// Register adds a backend to the default registry. Backends register
+// themselves on import, so callers need e.g.
+//
+//	import _ "github.com/kolobok01/util/client/docker"
+func Register(b Backend) {
+	registry.Register(b)
+}
+
+// New creates a Client from the default registry.
+func New(ctx context.Context, opts Options) (Client, error) {
+	return registry.New(ctx, opts)
+}
+
+func (r *Registry) Register(b Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends[b.Type] = b
+}
+
+// New creates a Client of the requested type or, when no type is given,
+// of the first backend by priority that detects a suitable environment
+// and can be connected to.
+func (r *Registry) New(ctx context.Context, opts Options) (Client, error) {
+	if opts.Type != "" {
+		r.mu.RLock()
+		b, ok := r.backends[opts.Type]
+		r.mu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown client type: %s", opts.Type)
+		}
+		return create(ctx, b, opts)
+	}
+
+	detectErr := &DetectError{}
+	for _, b := range r.sorted() {
+		err := b.Detect(ctx)
+		if err == nil {
+			var c Client
+			c, err = create(ctx, b, opts)
+			if err == nil {
+				return c, nil
+			}
+		}
+		if opts.Debug {
+			log.Printf("DEBUG: client backend %s rejected: %v", b.Type, err)
+		}
+		if opts.OnRejected != nil {
+			opts.OnRejected(b.Type, err)
+		}
+		detectErr.Rejected = append(detectErr.Rejected, Rejection{Backend: b.Type, Reason: err})
+	}
+	return nil, detectErr
+}
+
+func (r *Registry) sorted() []Backend {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var backends []Backend
+	for _, b := range r.backends {
+		backends = append(backends, b)
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		if backends[i].Priority != backends[j].Priority {
+			return backends[i].Priority < backends[j].Priority
+		}
+		return backends[i].Type < backends[j].Type
+	})
+	return backends
+}
+
+func create(ctx context.Context, b Backend, opts Options) (Client, error) {
+	if opts.OnVersion == nil {
+		opts.OnVersion = func(string) {}
+	}
+	c, err := b.New(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := c.(interface{ SetDebug(bool) }); ok && opts.Debug {
+		d.SetDebug(true)
+	}
+	return c, nil
+}
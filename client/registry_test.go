@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+)
+
+type typedClient struct {
+	Client
+	typ   string
+	debug bool
+}
+
+func (c *typedClient) GetType() string {
+	return c.typ
+}
+
+func (c *typedClient) SetDebug(debug bool) {
+	c.debug = debug
+}
+
+func backend(typ string, priority int, detectErr, newErr error) Backend {
+	return Backend{
+		Type:     typ,
+		Priority: priority,
+		Detect: func(ctx context.Context) error {
+			return detectErr
+		},
+		New: func(ctx context.Context, opts Options) (Client, error) {
+			if newErr != nil {
+				return nil, newErr
+			}
+			opts.OnVersion("1.0")
+			return &typedClient{typ: typ}, nil
+		},
+	}
+}
+
+func TestNewDetectsByPriority(t *testing.T) {
+	r := NewRegistry()
+	r.Register(backend("LAST", 30, nil, nil))
+	r.Register(backend("UNDETECTED", 10, errors.New("not here"), nil))
+	r.Register(backend("BROKEN", 20, nil, errors.New("connection refused")))
+
+	var rejected []string
+	c, err := r.New(context.Background(), Options{
+		Debug: true,
+		OnRejected: func(backend string, reason error) {
+			rejected = append(rejected, backend+": "+reason.Error())
+		},
+	})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, c.GetType(), EqualTo{"LAST"})
+	AssertThat(t, c.(*typedClient).debug, Is{true})
+	AssertThat(t, rejected, EqualTo{[]string{"UNDETECTED: not here", "BROKEN: connection refused"}})
+}
+
+func TestNewExplicitType(t *testing.T) {
+	r := NewRegistry()
+	r.Register(backend("FIRST", 10, nil, nil))
+	r.Register(backend("SECOND", 20, errors.New("not detected"), nil))
+
+	var version string
+	c, err := r.New(context.Background(), Options{Type: "SECOND", OnVersion: func(v string) { version = v }})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, c.GetType(), EqualTo{"SECOND"})
+	AssertThat(t, version, EqualTo{"1.0"})
+
+	_, err = r.New(context.Background(), Options{Type: "MISSING"})
+	AssertThat(t, err, Not{nil})
+}
+
+func TestNewNothingDetected(t *testing.T) {
+	r := NewRegistry()
+	r.Register(backend("ONLY", 10, errors.New("not here"), nil))
+	_, err := r.New(context.Background(), Options{})
+	detectErr, ok := err.(*DetectError)
+	AssertThat(t, ok, Is{true})
+	AssertThat(t, len(detectErr.Rejected), EqualTo{1})
+	AssertThat(t, err.Error(), EqualTo{"no suitable client backend found (ONLY: not here)"})
+}
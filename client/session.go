@@ -0,0 +1,83 @@
+package client
+
+import "time"
+
+// Session describes a browser container or pod created by BlueIO, as read
+// back from its labels and state.
+type Session struct {
+	ID        string    `json:"id"`
+	RequestID string    `json:"requestId,omitempty"`
+	SessionID string    `json:"sessionId,omitempty"`
+	Browser   string    `json:"browser,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	User      string    `json:"user,omitempty"`
+	State     string    `json:"state"`
+	Finished  bool      `json:"finished"`
+	Created   time.Time `json:"created"`
+}
+
+// Age returns how long ago the session was created.
+func (s Session) Age() time.Duration {
+	return time.Since(s.Created)
+}
+
+// SessionFromLabels fills the fields of a session that are stored in labels.
+func SessionFromLabels(id string, labels, annotations map[string]string) Session {
+	return Session{
+		ID:        id,
+		RequestID: labels[RequestIDLabel],
+		SessionID: labels[SessionIDLabel],
+		Browser:   labels[BrowserLabel],
+		Version:   labels[VersionLabel],
+		User:      annotations[UserAnnotation],
+	}
+}
+
+// SessionSettings are the options of a session every backend supports, the
+// backends build their session options from them.
+type SessionSettings struct {
+	Labels map[string]string
+	// Browser and Version label the session, they are parsed from the
+	// image when empty.
+	Browser string
+	Version string
+	// Port is the WebDriver port of the image, 4444 by default, and Path
+	// the base path of the WebDriver, / by default.
+	Port             int
+	Path             string
+	Env              map[string]string
+	ScreenResolution string
+	TimeZone         string
+	EnableVNC        bool
+}
+
+// Environment returns the environment of the browser, Env overrides the
+// variables set for the other settings.
+func (s SessionSettings) Environment() map[string]string {
+	env := make(map[string]string)
+	if s.ScreenResolution != "" {
+		env["SCREEN_RESOLUTION"] = s.ScreenResolution
+	}
+	if s.TimeZone != "" {
+		env["TZ"] = s.TimeZone
+	}
+	if s.EnableVNC {
+		env["ENABLE_VNC"] = "true"
+	}
+	for k, v := range s.Env {
+		env[k] = v
+	}
+	return env
+}
+
+// Probe points the probe at the WebDriver port and path of the image unless
+// the caller chose them.
+func (s SessionSettings) Probe(probe Probe) Probe {
+	if probe.Port == 0 && s.Port != 0 {
+		probe.Port = s.Port
+	}
+	if probe.Path == "" && s.Path != "" {
+		probe.Path = StatusPath(s.Path)
+	}
+	return probe
+}
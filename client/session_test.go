@@ -0,0 +1,33 @@
+package client
+
+import (
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+)
+
+func TestSessionSettingsEnvironment(t *testing.T) {
+	s := SessionSettings{
+		ScreenResolution: "1920x1080x24",
+		TimeZone:         "UTC",
+		EnableVNC:        true,
+		Env:              map[string]string{"TZ": "Europe/Moscow", "LANG": "en_US.UTF-8"},
+	}
+	AssertThat(t, s.Environment(), EqualTo{map[string]string{
+		"SCREEN_RESOLUTION": "1920x1080x24",
+		"TZ":                "Europe/Moscow",
+		"ENABLE_VNC":        "true",
+		"LANG":              "en_US.UTF-8",
+	}})
+}
+
+func TestSessionSettingsProbe(t *testing.T) {
+	s := SessionSettings{Port: 5555, Path: "/wd/hub/"}
+	probe := s.Probe(Probe{})
+	AssertThat(t, probe.Port, EqualTo{5555})
+	AssertThat(t, probe.Path, EqualTo{"/wd/hub/status"})
+
+	probe = s.Probe(Probe{Port: 4444, Path: "/ready"})
+	AssertThat(t, probe.Port, EqualTo{4444})
+	AssertThat(t, probe.Path, EqualTo{"/ready"})
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// statsParallelism bounds the sessions AggregateStats samples at once.
+const statsParallelism = 8
+
+// Stats is a single resource usage sample of a session container or pod.
+// Client.Stats sends one sample and closes the channel unless streaming is
+// requested, in which case samples keep coming until the context is done.
+// Network counters are zero when the backend does not report them, e.g. the
+// Kubernetes metrics API.
+type Stats struct {
+	ID          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	CPUPercent  float64   `json:"cpuPercent"`
+	MemoryUsage uint64    `json:"memoryUsage"`
+	MemoryLimit uint64    `json:"memoryLimit"`
+	NetworkRx   uint64    `json:"networkRx"`
+	NetworkTx   uint64    `json:"networkTx"`
+}
+
+// Usage is the resource usage summed over several sessions.
+type Usage struct {
+	Sessions    int     `json:"sessions"`
+	CPUPercent  float64 `json:"cpuPercent"`
+	MemoryUsage uint64  `json:"memoryUsage"`
+	MemoryLimit uint64  `json:"memoryLimit"`
+	NetworkRx   uint64  `json:"networkRx"`
+	NetworkTx   uint64  `json:"networkTx"`
+}
+
+// AggregateStats takes one sample from every session, a few sessions at
+// once, and sums them up. Sessions that can not be sampled, e.g. because
+// they have just exited, are skipped; an error is returned only when no
+// session could be sampled.
+func AggregateStats(ctx context.Context, c Client, ids []string) (*Usage, error) {
+	usage := &Usage{}
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		lastErr error
+	)
+	slots := make(chan struct{}, statsParallelism)
+	for _, id := range ids {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			stats, err := c.Stats(ctx, id, false)
+			if err != nil {
+				log.Printf("WARNING: cannot get stats for %s: %v", id, err)
+				mu.Lock()
+				lastErr = err
+				mu.Unlock()
+				return
+			}
+			s, ok := <-stats
+			if !ok {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			usage.Sessions++
+			usage.CPUPercent += s.CPUPercent
+			usage.MemoryUsage += s.MemoryUsage
+			usage.MemoryLimit += s.MemoryLimit
+			usage.NetworkRx += s.NetworkRx
+			usage.NetworkTx += s.NetworkTx
+		}(id)
+	}
+	wg.Wait()
+	if usage.Sessions == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return usage, nil
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+)
+
+type statsClient struct {
+	Client
+	stats map[string]Stats
+}
+
+func (c *statsClient) Stats(ctx context.Context, id string, stream bool) (<-chan Stats, error) {
+	s, ok := c.stats[id]
+	if !ok {
+		return nil, errors.New("no such container")
+	}
+	out := make(chan Stats, 1)
+	out <- s
+	close(out)
+	return out, nil
+}
+
+func (c *statsClient) GetLogs(ctx context.Context, id string) (io.ReadCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestAggregateStats(t *testing.T) {
+	c := &statsClient{stats: map[string]Stats{
+		"first":  {CPUPercent: 10, MemoryUsage: 100, MemoryLimit: 1000, NetworkRx: 1, NetworkTx: 2},
+		"second": {CPUPercent: 5, MemoryUsage: 50, MemoryLimit: 500, NetworkRx: 3, NetworkTx: 4},
+	}}
+	usage, err := AggregateStats(context.Background(), c, []string{"first", "second", "gone"})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, *usage, EqualTo{Usage{Sessions: 2, CPUPercent: 15, MemoryUsage: 150, MemoryLimit: 1500, NetworkRx: 4, NetworkTx: 6}})
+}
+
+func TestAggregateStatsAllFailed(t *testing.T) {
+	_, err := AggregateStats(context.Background(), &statsClient{}, []string{"gone"})
+	AssertThat(t, err, Not{nil})
+}
+
+// barrierClient answers once every session is being sampled, i.e. only when
+// the sessions are sampled at once.
+type barrierClient struct {
+	statsClient
+	once    sync.Once
+	arrived chan struct{}
+	all     chan struct{}
+}
+
+func (c *barrierClient) Stats(ctx context.Context, id string, stream bool) (<-chan Stats, error) {
+	c.arrived <- struct{}{}
+	if len(c.arrived) == cap(c.arrived) {
+		c.once.Do(func() { close(c.all) })
+	}
+	select {
+	case <-c.all:
+		return c.statsClient.Stats(ctx, id, stream)
+	case <-time.After(5 * time.Second):
+		return nil, errors.New("sampled one at a time")
+	}
+}
+
+func TestAggregateStatsSamplesAtOnce(t *testing.T) {
+	c := &barrierClient{
+		statsClient: statsClient{stats: map[string]Stats{"first": {MemoryUsage: 1}, "second": {MemoryUsage: 2}, "third": {MemoryUsage: 3}}},
+		arrived:     make(chan struct{}, 3),
+		all:         make(chan struct{}),
+	}
+	usage, err := AggregateStats(context.Background(), c, []string{"first", "second", "third"})
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, usage.Sessions, EqualTo{3})
+	AssertThat(t, usage.MemoryUsage, EqualTo{uint64(6)})
+}
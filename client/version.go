@@ -0,0 +1,62 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CompareVersions orders dotted versions component by component, so that
+// 10.10 comes after 10.9 and 120 after 99. Numeric components come before
+// the others, which compare as strings. A version is smaller than the
+// versions it prefixes.
+func CompareVersions(v1, v2 string) int {
+	p1, p2 := strings.Split(v1, "."), strings.Split(v2, ".")
+	for i := 0; i < len(p1) && i < len(p2); i++ {
+		if c := compareComponents(p1[i], p2[i]); c != 0 {
+			return c
+		}
+	}
+	return len(p1) - len(p2)
+}
+
+func compareComponents(c1, c2 string) int {
+	n1, err1 := strconv.ParseUint(c1, 10, 64)
+	n2, err2 := strconv.ParseUint(c2, 10, 64)
+	switch {
+	case err1 == nil && err2 == nil:
+		switch {
+		case n1 < n2:
+			return -1
+		case n1 > n2:
+			return 1
+		}
+		return 0
+	case err1 == nil:
+		return -1
+	case err2 == nil:
+		return 1
+	}
+	return strings.Compare(c1, c2)
+}
+
+// HasVersionPrefix reports whether the version is the prefix or starts with
+// the prefix followed by a dot, i.e. 120 matches 120.0.6099 but not 1200.
+func HasVersionPrefix(version, prefix string) bool {
+	return version == prefix || strings.HasPrefix(version, prefix+".")
+}
+
+// validVersion accepts dotted versions starting with a number, e.g. 70.0 or
+// 120.0.6099.109.
+func validVersion(version string) error {
+	first := strings.SplitN(version, ".", 2)[0]
+	if _, err := strconv.ParseUint(first, 10, 64); err != nil {
+		return fmt.Errorf("incorrect version %q, should start with a number", version)
+	}
+	for _, c := range strings.Split(version, ".") {
+		if c == "" {
+			return fmt.Errorf("incorrect version %q, has an empty component", version)
+		}
+	}
+	return nil
+}
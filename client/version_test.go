@@ -0,0 +1,21 @@
+package client
+
+import (
+	"testing"
+
+	. "github.com/aandryashin/matchers"
+)
+
+func TestCompareVersions(t *testing.T) {
+	AssertThat(t, CompareVersions("10.10", "10.9") > 0, Is{true})
+	AssertThat(t, CompareVersions("120.0", "99.0") > 0, Is{true})
+	AssertThat(t, CompareVersions("70.0", "70.0"), EqualTo{0})
+	AssertThat(t, CompareVersions("70", "70.0") < 0, Is{true})
+	AssertThat(t, CompareVersions("70.0", "70.beta") < 0, Is{true})
+}
+
+func TestHasVersionPrefix(t *testing.T) {
+	AssertThat(t, HasVersionPrefix("120.0.6099", "120"), Is{true})
+	AssertThat(t, HasVersionPrefix("120", "120"), Is{true})
+	AssertThat(t, HasVersionPrefix("1200.0", "120"), Is{false})
+}
@@ -0,0 +1,113 @@
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+type QueueOrder string
+
+const (
+	// FIFO serves waiting requests in arrival order.
+	FIFO QueueOrder = "fifo"
+	// Priority serves higher priorities first and equal priorities in
+	// arrival order.
+	Priority QueueOrder = "priority"
+)
+
+// Config is read from a JSON file like:
+//
+//	{
+//	  "total": 20,
+//	  "perUser": 5,
+//	  "users": {"alice": 10},
+//	  "queue": "priority",
+//	  "queueDepth": 100,
+//	  "queueTimeout": "1m"
+//	}
+type Config struct {
+	// Total caps the sessions of all users, zero means unlimited.
+	Total int
+	// PerUser caps the sessions of every user not in Users, zero means
+	// unlimited.
+	PerUser int
+	// Users override PerUser for some users, zero means unlimited.
+	Users map[string]int
+	// Queue is FIFO by default.
+	Queue QueueOrder
+	// QueueDepth rejects requests that would have to wait when that many
+	// are already waiting, zero means unlimited.
+	QueueDepth int
+	// QueueTimeout rejects requests waiting longer than that, zero waits
+	// until the context of the request is done.
+	QueueTimeout time.Duration
+}
+
+type configFile struct {
+	Total        int            `json:"total"`
+	PerUser      int            `json:"perUser"`
+	Users        map[string]int `json:"users"`
+	Queue        QueueOrder     `json:"queue"`
+	QueueDepth   int            `json:"queueDepth"`
+	QueueTimeout string         `json:"queueTimeout"`
+}
+
+func LoadConfig(path string) (Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(data)
+}
+
+func ParseConfig(data []byte) (Config, error) {
+	var f configFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Config{}, fmt.Errorf("cannot parse quota config: %v", err)
+	}
+	c := Config{
+		Total:      f.Total,
+		PerUser:    f.PerUser,
+		Users:      f.Users,
+		Queue:      f.Queue,
+		QueueDepth: f.QueueDepth,
+	}
+	if f.QueueTimeout != "" {
+		timeout, err := time.ParseDuration(f.QueueTimeout)
+		if err != nil {
+			return Config{}, fmt.Errorf("incorrect queue timeout: %v", err)
+		}
+		c.QueueTimeout = timeout
+	}
+	if err := c.validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+func (c Config) validate() error {
+	switch c.Queue {
+	case "", FIFO, Priority:
+	default:
+		return fmt.Errorf("unknown queue order: %s", c.Queue)
+	}
+	if c.Total < 0 || c.PerUser < 0 || c.QueueDepth < 0 || c.QueueTimeout < 0 {
+		return fmt.Errorf("quota limits can not be negative")
+	}
+	for user, limit := range c.Users {
+		if limit < 0 {
+			return fmt.Errorf("quota limit of %s can not be negative", user)
+		}
+	}
+	return nil
+}
+
+// limit of the user, zero means unlimited.
+func (c Config) limit(user string) int {
+	if limit, ok := c.Users[user]; ok {
+		return limit
+	}
+	return c.PerUser
+}
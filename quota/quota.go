@@ -0,0 +1,221 @@
+// Package quota caps the concurrent browser sessions globally and per user.
+// Requests over the limits wait in a queue.
+package quota
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kolobok01/util"
+)
+
+var (
+	ErrQueueFull    = errors.New("quota queue is full")
+	ErrQueueTimeout = errors.New("timed out waiting in quota queue")
+)
+
+// Release gives the session slot back, calling it more than once is safe.
+type Release func()
+
+type UserStats struct {
+	Running int `json:"running"`
+	Queued  int `json:"queued"`
+	// Limit is zero for unlimited users.
+	Limit int `json:"limit"`
+}
+
+type Stats struct {
+	Running int                  `json:"running"`
+	Queued  int                  `json:"queued"`
+	Limit   int                  `json:"limit"`
+	Users   map[string]UserStats `json:"users"`
+}
+
+type waiter struct {
+	user     string
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	granted  bool
+}
+
+type Manager struct {
+	config  Config
+	running map[string]int
+	total   int
+	queue   []*waiter
+	seq     uint64
+	mu      sync.Mutex
+}
+
+func NewManager(config Config) (*Manager, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &Manager{config: config, running: make(map[string]int)}, nil
+}
+
+// SetConfig applies new limits, requests that fit them leave the queue at
+// once. Running sessions above lowered limits are left alone.
+func (m *Manager) SetConfig(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = config
+	sort.SliceStable(m.queue, func(i, j int) bool {
+		return m.before(m.queue[i], m.queue[j])
+	})
+	m.dispatch()
+	return nil
+}
+
+// Reload reads the config file again, the current config is kept when the
+// file is incorrect.
+func (m *Manager) Reload(path string) error {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	return m.SetConfig(config)
+}
+
+// AcquireRequest acquires a slot for the user of the request.
+func (m *Manager) AcquireRequest(r *http.Request, priority int) (Release, error) {
+	user, _ := util.RequestInfo(r)
+	return m.Acquire(r.Context(), user, priority)
+}
+
+// Acquire returns once the user may start a session. The priority only
+// matters with the Priority queue order.
+func (m *Manager) Acquire(ctx context.Context, user string, priority int) (Release, error) {
+	m.mu.Lock()
+	m.seq++
+	w := &waiter{user: user, priority: priority, seq: m.seq, ready: make(chan struct{})}
+	m.enqueue(w)
+	m.dispatch()
+	// a request that gets a slot at once never counts against the depth
+	if !w.granted && m.config.QueueDepth > 0 && len(m.queue) > m.config.QueueDepth {
+		m.remove(w)
+		m.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	timeout := m.config.QueueTimeout
+	m.mu.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var err error
+	select {
+	case <-w.ready:
+		return m.release(user), nil
+	case <-expired:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if w.granted {
+		return m.release(user), nil
+	}
+	m.remove(w)
+	// a request the others waited behind may have left
+	m.dispatch()
+	return nil, err
+}
+
+func (m *Manager) release(user string) Release {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.total--
+			m.running[user]--
+			if m.running[user] == 0 {
+				delete(m.running, user)
+			}
+			m.dispatch()
+		})
+	}
+}
+
+func (m *Manager) before(w1, w2 *waiter) bool {
+	if m.config.Queue == Priority && w1.priority != w2.priority {
+		return w1.priority > w2.priority
+	}
+	return w1.seq < w2.seq
+}
+
+func (m *Manager) enqueue(w *waiter) {
+	i := sort.Search(len(m.queue), func(i int) bool {
+		return m.before(w, m.queue[i])
+	})
+	m.queue = append(m.queue, nil)
+	copy(m.queue[i+1:], m.queue[i:])
+	m.queue[i] = w
+}
+
+func (m *Manager) remove(w *waiter) {
+	for i, queued := range m.queue {
+		if queued == w {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// dispatch grants the waiters that fit the limits in queue order. A user at
+// its limit does not hold up the users behind it.
+func (m *Manager) dispatch() {
+	var waiting []*waiter
+	for _, w := range m.queue {
+		if m.config.Total > 0 && m.total >= m.config.Total {
+			waiting = append(waiting, w)
+			continue
+		}
+		if limit := m.config.limit(w.user); limit > 0 && m.running[w.user] >= limit {
+			waiting = append(waiting, w)
+			continue
+		}
+		m.total++
+		m.running[w.user]++
+		w.granted = true
+		close(w.ready)
+	}
+	m.queue = waiting
+}
+
+func (m *Manager) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := Stats{
+		Running: m.total,
+		Queued:  len(m.queue),
+		Limit:   m.config.Total,
+		Users:   make(map[string]UserStats),
+	}
+	for user, running := range m.running {
+		stats.Users[user] = UserStats{Running: running, Limit: m.config.limit(user)}
+	}
+	for _, w := range m.queue {
+		s, ok := stats.Users[w.user]
+		if !ok {
+			s.Limit = m.config.limit(w.user)
+		}
+		s.Queued++
+		stats.Users[w.user] = s
+	}
+	return stats
+}
@@ -0,0 +1,172 @@
+package quota
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/aandryashin/matchers"
+)
+
+func acquired(ch <-chan Release) bool {
+	select {
+	case <-ch:
+		return true
+	case <-time.After(50 * time.Millisecond):
+		return false
+	}
+}
+
+func acquire(m *Manager, user string, priority int) <-chan Release {
+	ch := make(chan Release, 1)
+	go func() {
+		release, err := m.Acquire(context.Background(), user, priority)
+		if err == nil {
+			ch <- release
+		}
+	}()
+	return ch
+}
+
+func TestAcquireWithinLimits(t *testing.T) {
+	m, err := NewManager(Config{Total: 2, PerUser: 1})
+	AssertThat(t, err, Is{nil})
+	release, err := m.Acquire(context.Background(), "alice", 0)
+	AssertThat(t, err, Is{nil})
+	_, err = m.Acquire(context.Background(), "bob", 0)
+	AssertThat(t, err, Is{nil})
+
+	stats := m.Stats()
+	AssertThat(t, stats.Running, EqualTo{2})
+	AssertThat(t, stats.Users["alice"], EqualTo{UserStats{Running: 1, Limit: 1}})
+
+	release()
+	release()
+	AssertThat(t, m.Stats().Running, EqualTo{1})
+}
+
+func TestQueueFIFO(t *testing.T) {
+	m, _ := NewManager(Config{Total: 1})
+	release, err := m.Acquire(context.Background(), "alice", 0)
+	AssertThat(t, err, Is{nil})
+
+	first := acquire(m, "bob", 0)
+	time.Sleep(10 * time.Millisecond)
+	second := acquire(m, "carol", 10)
+	time.Sleep(10 * time.Millisecond)
+	AssertThat(t, m.Stats().Queued, EqualTo{2})
+
+	release()
+	AssertThat(t, acquired(first), Is{true})
+	AssertThat(t, acquired(second), Is{false})
+}
+
+func TestQueuePriority(t *testing.T) {
+	m, _ := NewManager(Config{Total: 1, Queue: Priority})
+	release, _ := m.Acquire(context.Background(), "alice", 0)
+
+	low := acquire(m, "bob", 0)
+	time.Sleep(10 * time.Millisecond)
+	high := acquire(m, "carol", 10)
+	time.Sleep(10 * time.Millisecond)
+
+	release()
+	AssertThat(t, acquired(high), Is{true})
+	AssertThat(t, acquired(low), Is{false})
+}
+
+func TestUserLimitDoesNotBlockOthers(t *testing.T) {
+	m, _ := NewManager(Config{Total: 3, PerUser: 1, Users: map[string]int{"carol": 2}})
+	m.Acquire(context.Background(), "alice", 0)
+
+	blocked := acquire(m, "alice", 0)
+	AssertThat(t, acquired(blocked), Is{false})
+	AssertThat(t, acquired(acquire(m, "carol", 0)), Is{true})
+	AssertThat(t, acquired(acquire(m, "carol", 0)), Is{true})
+
+	stats := m.Stats()
+	AssertThat(t, stats.Users["alice"], EqualTo{UserStats{Running: 1, Queued: 1, Limit: 1}})
+	AssertThat(t, stats.Users["carol"], EqualTo{UserStats{Running: 2, Limit: 2}})
+}
+
+func TestQueueTimeoutAndDepth(t *testing.T) {
+	m, _ := NewManager(Config{Total: 1, QueueDepth: 1, QueueTimeout: 20 * time.Millisecond})
+	m.Acquire(context.Background(), "alice", 0)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := m.Acquire(context.Background(), "bob", 0)
+		errs <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	_, err := m.Acquire(context.Background(), "carol", 0)
+	AssertThat(t, err, Is{ErrQueueFull})
+	AssertThat(t, <-errs, Is{ErrQueueTimeout})
+	AssertThat(t, m.Stats().Queued, EqualTo{0})
+}
+
+func TestFullQueueStillGrantsFreeSlots(t *testing.T) {
+	m, _ := NewManager(Config{PerUser: 1, QueueDepth: 1})
+	m.Acquire(context.Background(), "alice", 0)
+	blocked := acquire(m, "alice", 0)
+	AssertThat(t, acquired(blocked), Is{false})
+	AssertThat(t, m.Stats().Queued, EqualTo{1})
+
+	_, err := m.Acquire(context.Background(), "bob", 0)
+	AssertThat(t, err, Is{nil})
+	_, err = m.Acquire(context.Background(), "alice", 0)
+	AssertThat(t, err, Is{ErrQueueFull})
+	AssertThat(t, m.Stats().Queued, EqualTo{1})
+}
+
+func TestAcquireCanceled(t *testing.T) {
+	m, _ := NewManager(Config{Total: 1})
+	m.Acquire(context.Background(), "alice", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := m.Acquire(ctx, "bob", 0)
+	AssertThat(t, err, Is{context.Canceled})
+}
+
+func TestAcquireRequest(t *testing.T) {
+	m, _ := NewManager(Config{PerUser: 1})
+	req := httptest.NewRequest("POST", "/wd/hub/session", nil)
+	req.SetBasicAuth("alice", "secret")
+	_, err := m.AcquireRequest(req, 0)
+	AssertThat(t, err, Is{nil})
+	AssertThat(t, m.Stats().Users["alice"].Running, EqualTo{1})
+}
+
+func TestReload(t *testing.T) {
+	m, _ := NewManager(Config{Total: 1})
+	m.Acquire(context.Background(), "alice", 0)
+	waiting := acquire(m, "bob", 0)
+	AssertThat(t, acquired(waiting), Is{false})
+
+	f, err := ioutil.TempFile("", "quota")
+	AssertThat(t, err, Is{nil})
+	defer os.Remove(f.Name())
+	f.WriteString(`{"total": 2, "perUser": 1, "queue": "priority", "queueDepth": 10, "queueTimeout": "1m"}`)
+	f.Close()
+
+	AssertThat(t, m.Reload(f.Name()), Is{nil})
+	AssertThat(t, acquired(waiting), Is{true})
+	AssertThat(t, m.Stats().Limit, EqualTo{2})
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	for _, data := range []string{
+		`[]`,
+		`{"queue": "lifo"}`,
+		`{"total": -1}`,
+		`{"users": {"alice": -1}}`,
+		`{"queueTimeout": "soon"}`,
+	} {
+		_, err := ParseConfig([]byte(data))
+		AssertThat(t, err, Not{nil})
+	}
+}